@@ -0,0 +1,70 @@
+// Command demo prints a few sequences computed with goiter.
+//
+// demo 命令打印几个使用 goiter 计算的序列。
+package main
+
+import (
+	"fmt"
+
+	"github.com/CoderYihaoWang/goiter"
+)
+
+// Run:
+// go run ./cmd/demo
+//
+// Test:
+// go test ./...
+func main() {
+	// print the squares of 1 through 20
+	// 打印 1 到 20 的平方
+	fmt.Printf("Squares of 1 ~ 20: %v\n", squares(20))
+
+	// print the factorial of 10
+	// 打印 10 的阶乘
+	fmt.Printf("Factorial of 10: %d\n", fac(10))
+
+	// print the first 100 prime numbers
+	// 打印前 100 个质数
+	fmt.Printf("The first 100 prime numbers: %v\n", primes(100))
+
+	// print the first 10 positive integers that leave a remainder of 1 when divided by 4
+	// 打印前 10 个除以 4 余 1 的正整数
+	fmt.Printf("The first 10 integers of the form 4k+1: %v\n", goiter.Arithmetic(1, 4).Take(10).Collect())
+
+	// print all the powers of 3 that fit in an int
+	// 打印 int 能表示的所有 3 的幂
+	fmt.Printf("Powers of 3: %v\n", goiter.Geometric(1, 3).Collect())
+
+	// print the sum of the digits of 2021
+	// 打印 2021 的各位数字之和
+	fmt.Printf("Digit sum of 2021: %d\n", digitSum(2021))
+}
+
+// squares of 1 ~ n, inclusive
+// 返回 1 ~ n 间整数的平方，包含端点
+func squares(n int) []int {
+	return goiter.RangeInclusive(1, n).
+		Map(func(x int) int { return x * x }).
+		Collect()
+}
+
+// the factorial of positive integer n
+// 计算正整数 n 的阶乘。
+func fac(n int) int {
+	return goiter.Product(goiter.RangeInclusive(1, n))
+}
+
+// the sum of the digits of n
+// 计算 n 的各位数字之和
+func digitSum(n int) int {
+	return goiter.Digits(n).
+		Reduce(0, func(acc, cur int) int { return acc + cur })
+}
+
+// first n-th prime numbers
+// 返回前 n 个质数
+func primes(n int) []int {
+	return goiter.Primes().
+		Take(n).
+		Collect()
+}
@@ -0,0 +1,72 @@
+package goiter_test
+
+import (
+	"fmt"
+
+	"github.com/CoderYihaoWang/goiter"
+)
+
+// the squares of 1 ~ 20, inclusive
+// 1 到 20 的平方，包含端点
+func ExampleRangeInclusive() {
+	squares := goiter.RangeInclusive(1, 20).
+		Map(func(x int) int { return x * x }).
+		Collect()
+	fmt.Println(squares)
+	// Output: [1 4 9 16 25 36 49 64 81 100 121 144 169 196 225 256 289 324 361 400]
+}
+
+// the factorial of 10
+// 10 的阶乘
+func ExampleProduct() {
+	fmt.Println(goiter.Product(goiter.RangeInclusive(1, 10)))
+	// Output: 3628800
+}
+
+// the first 20 prime numbers
+// 前 20 个质数
+func ExamplePrimes() {
+	fmt.Println(goiter.Primes().Take(20).Collect())
+	// Output: [2 3 5 7 11 13 17 19 23 29 31 37 41 43 47 53 59 61 67 71]
+}
+
+// the first 10 positive integers that leave a remainder of 1 when divided by 4
+// 前 10 个除以 4 余 1 的正整数
+func ExampleArithmetic() {
+	fmt.Println(goiter.Arithmetic(1, 4).Take(10).Collect())
+	// Output: [1 5 9 13 17 21 25 29 33 37]
+}
+
+// the first 7 powers of 3
+// 3 的前 7 个幂
+func ExampleGeometric() {
+	fmt.Println(goiter.Geometric(1, 3).Take(7).Collect())
+	// Output: [1 3 9 27 81 243 729]
+}
+
+// adding a constant to every element, by zipping with a repeated value
+// 通过与重复值 Zip，给每个元素加上一个常数
+func ExampleRepeat() {
+	var sums []int
+	for p := range goiter.Zip(goiter.Repeat(10), goiter.RangeInclusive(1, 5)) {
+		sums = append(sums, p.First+p.Second)
+	}
+	fmt.Println(sums)
+	// Output: [11 12 13 14 15]
+}
+
+// the sum of the digits of 2021
+// 2021 的各位数字之和
+func ExampleDigits() {
+	fmt.Println(goiter.Digits(2021).Reduce(0, func(acc, cur int) int { return acc + cur }))
+	// Output: 5
+}
+
+// the words of a sentence with their lengths
+// 句子中的单词及其长度
+func ExampleMap() {
+	words := goiter.FromSlices([][]string{{"channels", "as", "iterators"}})
+	lengths := goiter.Map(words, func(w string) string { return fmt.Sprintf("%s:%d", w, len(w)) })
+	fmt.Println(lengths.Collect())
+	// Output: [channels:8 as:2 iterators:9]
+}
@@ -0,0 +1,7 @@
+package goiter
+
+// SelectKth exposes selectKth to the external tests, which benchmark it against sorting.
+var SelectKth = selectKth[int]
+
+// CollectCap exposes collectCap to the external tests, which check that it bounds the pre-allocation of Collect.
+var CollectCap = collectCap[int]
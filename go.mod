@@ -0,0 +1,3 @@
+module github.com/CoderYihaoWang/goiter
+
+go 1.23
@@ -1,25 +1,351 @@
-package main
+// Package goiter implements lazy, possibly infinite iterators on top of Go channels.
+// An Iter is a receive-only channel that a goroutine feeds, so it can be ranged over like any other channel,
+// and its methods and functions chain into pipelines whose stages run concurrently.
+//
+// goiter 包基于 Go 语言的 channel 实现了延迟计算、可以无穷的迭代器。
+// Iter 是一个由 goroutine 输入元素的只读 channel，因此可以像其他 channel 一样使用 range 遍历，
+// 而它的方法和函数可以串联成各个阶段并发执行的流水线。
+package goiter
+
+import (
+	"bufio"
+	"cmp"
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"io"
+	"iter"
+	"math"
+	"math/bits"
+	"math/rand"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
 
-// Iter demostrates how to use a Go channels to mimic iterators.
-// Note that this program is for demostration purpose only,
-// to simplify things, we only use int as the type of elements,
+// Iter demostrates how to use a Go channels to mimic iterators, with elements of any type T.
+// Operations that keep the element type are methods, so they can be chained fluently,
+// while those that change it, such as Map to another type and Reduce to another type of accumulator,
+// or that need a constraint on T, such as Sum, Min and Contains, are package-level functions,
+// because a method cannot introduce type parameters of its own.
+// Note that this package is for demostration purpose only,
 // and many necessary boundary checkings and error handlings in the methods are omitted.
 //
-// Iter 类型展示了怎样使用 Go 语言的 channel 来模拟迭代器。
-// 提示：本程序仅用作探索展示使用。简便起见，我们只支持 int 作为元素类型，
-// 并且在下面的方法中，许多必要的边界检查和错误处理都被略过了。
-type Iter <-chan int
+// Iter 类型展示了怎样使用 Go 语言的 channel 来模拟迭代器，元素可以是任意类型 T。
+// 不改变元素类型的操作是方法，因此可以链式调用；而改变元素类型的操作（例如映射为另一类型的 Map、
+// 加总为另一类型的 Reduce），以及需要对 T 加以约束的操作（例如 Sum、Min、Contains）是包级函数，
+// 因为方法不能引入自己的类型参数。
+// 提示：本包仅用作探索展示使用，在下面的方法中，许多必要的边界检查和错误处理都被略过了。
+type Iter[T any] <-chan T
+
+// Close stops the Iter and every Iter it was created from, releasing their goroutines.
+// Call it when abandoning an Iter before it ends, e.g. after breaking out of a for range loop over it;
+// the methods that stop consuming early once they know their result, such as Take, Any, Find and TryForEach,
+// close the Iter themselves, while those consuming a given number of elements, such as First, Nth, CollectN
+// and DrainN, leave the rest of it to the caller.
+// After Close, the Iter is closed shortly, so a for range loop over it exits.
+// Calling Close on an Iter that has ended, more than once, or concurrently is safe.
+// An Iter that was not created by this package, e.g. a converted channel, is not affected by Close.
+//
+// Close 方法停止该迭代器，以及创建它所依赖的所有迭代器，并释放它们的 goroutine。
+// 在迭代器结束前放弃它时（例如从遍历它的 for range 循环中 break 之后），应调用此方法；
+// Take、Any、Find、TryForEach 等在得到结果后便提前停止消费的方法会自行关闭迭代器，
+// 而 First、Nth、CollectN、DrainN 等消费给定个数元素的方法则将剩余部分留给调用者。
+// 调用 Close 之后，迭代器会很快被关闭，因此遍历它的 for range 循环会退出。
+// 在已结束的迭代器上调用 Close、多次调用或并发调用都是安全的。
+// 不是由本包创建的迭代器（例如直接转换得到的 channel）不受 Close 影响。
+func (it Iter[T]) Close() {
+	closeStage(it)
+}
+
+// stage is the cancellation state of an Iter created by this package. Every stage runs in its own goroutine,
+// which selects on done whenever it sends, and closes its upstreams when it finishes or is cancelled,
+// so that cancelling the last stage of a pipeline releases the whole pipeline.
+type stage struct {
+	done      chan struct{}
+	once      sync.Once
+	key       interface{}
+	closeCh   func()
+	upstreams []interface{ Close() }
+	// size is the number of elements the stage sends in total, or at most if not exact, or -1 if unknown.
+	size  int
+	exact bool
+	// unbounded is set if the stage is known never to end by itself, see Unbounded.
+	unbounded bool
+	// rng is the range the stage sends if it was created by Range, RangeInclusive or RangeStep,
+	// or by Map on such a stage, so that Checkpoint can record its position.
+	rng *RangeState
+
+	// The rest is the replay buffer of String. When String asks on peek, the element the stage is sending
+	// is appended to held instead, and emit sends the held elements later on in flush. While held is not empty,
+	// flushed is open and the stage sends nothing else, so that the elements stay in order.
+	// Only the stages of an Iter, not those of a PairIter, a BatchIter or a ResIter, have peek and emit.
+	// emit also receives from synced, so that String can wait for flush to drop an element just sent from held.
+	// holding is set while held is not empty, so that send only takes mu then.
+	peek     chan chan struct{}
+	synced   chan struct{}
+	emit     func(x interface{}) bool
+	holding  atomic.Bool
+	mu       sync.Mutex
+	held     []interface{}
+	flushed  chan struct{}
+	flushing bool
+	// peekMu lets only one String at a time ask for elements.
+	peekMu sync.Mutex
+}
+
+// stages maps the channel of every running stage, as an Iter, a PairIter, a BatchIter or a ResIter, to its *stage.
+var stages sync.Map
+
+// newStage creates the channel of a new stage reading from upstreams, and registers its stage.
+func newStage[T any](upstreams ...interface{ Close() }) (chan T, *stage) {
+	return newSizedStage[T](-1, false, upstreams...)
+}
+
+// newSizedStage is like newStage, but records the size hint of the new stage, see SizeHint.
+func newSizedStage[T any](size int, exact bool, upstreams ...interface{ Close() }) (chan T, *stage) {
+	ch := make(chan T)
+	s := register(Iter[T](ch), func() { close(ch) }, size, exact, upstreams...)
+	s.peek = make(chan chan struct{})
+	s.synced = make(chan struct{})
+	s.emit = func(x interface{}) bool {
+		for {
+			select {
+			case ch <- x.(T):
+				return true
+			case <-s.done:
+				return false
+			case <-s.synced:
+			}
+		}
+	}
+	return ch, s
+}
+
+// newUnboundedStage is like newStage, but marks the new stage as unbounded.
+func newUnboundedStage[T any](upstreams ...interface{ Close() }) (chan T, *stage) {
+	ch, s := newStage[T](upstreams...)
+	s.unbounded = true
+	return ch, s
+}
+
+func register(key interface{}, closeCh func(), size int, exact bool, upstreams ...interface{ Close() }) *stage {
+	if size < 0 {
+		size, exact = -1, false
+	}
+	s := &stage{done: make(chan struct{}), key: key, closeCh: closeCh, upstreams: upstreams, size: size, exact: exact}
+	stages.Store(key, s)
+	return s
+}
+
+// send sends x on ch, and reports false without sending if the stage is cancelled first.
+// x waits for the elements held back by String to be sent first, and is held back too if String asks for it.
+func send[T any](s *stage, ch chan<- T, x T) bool {
+	for {
+		var flushed chan struct{}
+		if s.holding.Load() {
+			s.mu.Lock()
+			flushed = s.flushed
+			s.mu.Unlock()
+		}
+		if flushed == nil {
+			select {
+			case ch <- x:
+				return true
+			case <-s.done:
+				return false
+			case ack := <-s.peek:
+				s.hold(x, ack)
+				return true
+			}
+		}
+		select {
+		case <-flushed:
+		case <-s.done:
+			return false
+		case ack := <-s.peek:
+			s.hold(x, ack)
+			return true
+		}
+	}
+}
+
+// hold appends x to the elements held back for String, and closes ack to tell String it is there.
+func (s *stage) hold(x interface{}, ack chan struct{}) {
+	s.mu.Lock()
+	s.held = append(s.held, x)
+	if s.flushed == nil {
+		s.flushed = make(chan struct{})
+		s.holding.Store(true)
+	}
+	s.mu.Unlock()
+	close(ack)
+}
+
+// flush sends the held elements in order, including those held back while it runs, and then closes flushed
+// to let the stage send again. It returns at once if there is nothing to send or another flush is running.
+func (s *stage) flush() {
+	s.mu.Lock()
+	if s.flushing || s.flushed == nil {
+		s.mu.Unlock()
+		return
+	}
+	s.flushing = true
+	s.mu.Unlock()
+	for {
+		s.mu.Lock()
+		if len(s.held) == 0 {
+			break
+		}
+		x := s.held[0]
+		s.mu.Unlock()
+		if !s.emit(x) {
+			s.mu.Lock()
+			s.held = nil
+			break
+		}
+		s.mu.Lock()
+		s.held = s.held[1:]
+		s.mu.Unlock()
+	}
+	close(s.flushed)
+	s.flushed, s.flushing = nil, false
+	s.holding.Store(false)
+	s.mu.Unlock()
+}
+
+// finish is deferred by the goroutine of the stage: it waits for the elements held back by String to be sent,
+// unregisters the stage, closes its channel and closes the upstreams. The stage stays registered while it waits,
+// so that Close can still cancel the sending of the held elements.
+func (s *stage) finish() {
+	s.mu.Lock()
+	flushed := s.flushed
+	s.mu.Unlock()
+	if flushed != nil {
+		<-flushed
+	}
+	stages.Delete(s.key)
+	s.closeCh()
+	s.cancel()
+}
+
+func (s *stage) cancel() {
+	s.once.Do(func() {
+		close(s.done)
+		for _, up := range s.upstreams {
+			up.Close()
+		}
+	})
+}
+
+func closeStage(key interface{}) {
+	if s, ok := stages.Load(key); ok {
+		s.(*stage).cancel()
+	}
+}
+
+// SizeHint returns (n, true) if the Iter is known to send exactly n elements in total, (n, false) if it is known
+// to send at most n elements, or (-1, false) if nothing is known. The hint is recorded when the Iter is created:
+// constructors of a known length, such as Range, RepeatN and FromSlices, record their length, Map and ParMap keep
+// the hint of the original Iter, Take and Drop adjust it, and the others, such as Filter and FromChan, have no hint.
+// It counts the elements from the start, including those already received, and an Iter that has ended has no hint.
+// Collect uses an exact hint to allocate its slice at once.
+//
+// SizeHint 方法在已知迭代器总共恰好发送 n 个元素时返回 (n, true)，在已知其最多发送 n 个元素时返回 (n, false)，
+// 若一无所知则返回 (-1, false)。该提示在迭代器创建时记录：Range、RepeatN、FromSlices 等已知长度的构造函数会记录其长度，
+// Map 和 ParMap 保留原迭代器的提示，Take 和 Drop 会调整提示，而 Filter、FromChan 等其他方法则没有提示。
+// 提示从头开始计数，包括已经接收的元素；已结束的迭代器没有提示。Collect 会使用确切的提示来一次性分配 slice。
+func (it Iter[T]) SizeHint() (n int, exact bool) {
+	if s, ok := stages.Load(it); ok {
+		return s.(*stage).size, s.(*stage).exact
+	}
+	return -1, false
+}
+
+// Unbounded reports whether the Iter is known never to end by itself, so that consuming all of it would hang.
+// Like the size hint, it is recorded when the Iter is created: infinite constructors, such as Seq, Repeat,
+// Primes and Random, are unbounded, Map, Filter, Drop and the other stages that forward the elements keep the flag
+// of the original Iter, while Take bounds it again and WithContext makes it unknown,
+// and an Iter of unknown origin, such as a converted channel, is never reported as unbounded.
+// Collect, Count, Reduce and ParReduce panic at once on an unbounded Iter instead of hanging.
+//
+// Unbounded 方法报告该迭代器是否已知永远不会自行结束，即消费它的全部元素会永远挂起。
+// 与大小提示一样，该标记在迭代器创建时记录：Seq、Repeat、Primes、Random 等无穷构造函数生成的迭代器是无界的，
+// Map、Filter、Drop 以及其他转发元素的阶段保留原迭代器的标记，而 Take 会使其重新有界，WithContext 则使其未知；来源未知的迭代器（例如直接转换得到的 channel）
+// 永远不会被报告为无界。Collect、Count、Reduce 和 ParReduce 在无界的迭代器上会立即 panic，而不是挂起。
+func (it Iter[T]) Unbounded() bool {
+	// a closed Iter ends soon even if it was unbounded
+	s, ok := stages.Load(it)
+	return ok && s.(*stage).unbounded && !isDone(s.(*stage))
+}
+
+// mustBeBounded closes the Iter and panics, naming the operation op, if the Iter is unbounded.
+func mustBeBounded[T any](op string, it Iter[T]) {
+	if it.Unbounded() {
+		it.Close()
+		panic(op + ": the Iter is unbounded and would never end, bound it with Take first")
+	}
+}
+
+// rangeSize returns the number of elements from from up to but excluding to by step, or -1 if it overflows an int.
+func rangeSize(from, to, step int) int {
+	if step == math.MinInt {
+		return -1
+	}
+	if step < 0 {
+		from, to, step = to, from, -step
+	}
+	if from >= to {
+		return 0
+	}
+	d := to - from
+	if d < 0 {
+		return -1
+	}
+	return (d-1)/step + 1
+}
+
+// closedIter returns an Iter that has already ended, without starting a goroutine.
+func closedIter[T any]() Iter[T] {
+	ch := make(chan T)
+	close(ch)
+	return ch
+}
 
 // Map creates a new Iter whose elements are projected from those of the original Iter
-// by applying the fn argument.
+// by applying the fn argument. Use the Map function to project them to another type.
 //
 // Map 方法生成一个新的迭代器，并使用参数 fn 将旧迭代器中的元素映射到新迭代器中。
-func (it Iter) Map(fn func(int) int) Iter {
-	ch := make(chan int)
+// 若要映射为另一类型，请使用 Map 函数。
+func (it Iter[T]) Map(fn func(T) T) Iter[T] {
+	return Map(it, fn)
+}
+
+// Map creates a new Iter whose elements, of type U, are projected from those of it by applying fn.
+//
+// Map 函数生成一个元素类型为 U 的新迭代器，并使用 fn 将 it 中的元素映射到新迭代器中。
+func Map[T, U any](it Iter[T], fn func(T) U) Iter[U] {
+	size, exact := it.SizeHint()
+	ch, s := newSizedStage[U](size, exact, it)
+	s.unbounded = it.Unbounded()
+	s.rng = rangeOf(it)
 	go func() {
-		defer close(ch)
+		defer s.finish()
 		for x := range it {
-			ch <- fn(x)
+			if !send(s, ch, fn(x)) {
+				return
+			}
 		}
 	}()
 	return ch
@@ -29,13 +355,16 @@ func (it Iter) Map(fn func(int) int) Iter {
 // satisfies the pred argument.
 //
 // Filter 方法生成一个新的迭代器，只保留旧迭代器中满足 pred 条件的元素。
-func (it Iter) Filter(pred func(int) bool) Iter {
-	ch := make(chan int)
+func (it Iter[T]) Filter(pred func(T) bool) Iter[T] {
+	ch, s := newStage[T](it)
+	s.unbounded = it.Unbounded()
 	go func() {
-		defer close(ch)
+		defer s.finish()
 		for x := range it {
 			if pred(x) {
-				ch <- x
+				if !send(s, ch, x) {
+					return
+				}
 			}
 		}
 	}()
@@ -44,11 +373,23 @@ func (it Iter) Filter(pred func(int) bool) Iter {
 
 // Reduce aggregates the elements of the Iter by applying the fn argument.
 // The initial value is specified by the init argument.
-// DO NOT call Reduce on an infinite Iter, otherwise the program will enter an infinite loop.
+// DO NOT call Reduce on an infinite Iter, otherwise the program will enter an infinite loop;
+// it panics at once on an Iter that is known to be Unbounded.
 //
 // Reduce 方法对迭代器中的元素使用 fn 参数进行加总。init 参数是用于加总的初始值。
-// 不要在无穷迭代器上调用此方法，否则会导致死循环。
-func (it Iter) Reduce(init int, fn func(int, int) int) int {
+// 不要在无穷迭代器上调用此方法，否则会导致死循环；在已知无界（见 Unbounded）的迭代器上它会立即 panic。
+func (it Iter[T]) Reduce(init T, fn func(T, T) T) T {
+	return Reduce(it, init, fn)
+}
+
+// Reduce is like the Reduce method, but the accumulator may be of another type A than the elements.
+// DO NOT call this function on an infinite Iter, or it results in an infinite loop;
+// it panics at once on an Iter that is known to be Unbounded.
+//
+// Reduce 函数与 Reduce 方法相同，但累加值可以是与元素不同的类型 A。
+// 不要在无穷迭代器上调用此函数，否则会导致死循环；在已知无界（见 Unbounded）的迭代器上它会立即 panic。
+func Reduce[T, A any](it Iter[T], init A, fn func(A, T) A) A {
+	mustBeBounded("Reduce", it)
 	acc := init
 	for x := range it {
 		acc = fn(acc, x)
@@ -56,83 +397,3462 @@ func (it Iter) Reduce(init int, fn func(int, int) int) int {
 	return acc
 }
 
-// Range generates an Iter containing integers [from, to)
+// ReduceCtx is like Reduce, but gives up when ctx is done, returning the accumulator so far together with ctx.Err(),
+// and closing the Iter without consuming the rest of it. So it can bound the time spent on an Iter that stalls
+// or turns out to be infinite. Use the ReduceCtx function to accumulate into another type.
+//
+// ReduceCtx 方法与 Reduce 相同，但在 ctx 结束时放弃，返回目前为止的累加值以及 ctx.Err()，并关闭迭代器而不消费剩余的元素。
+// 因此它可以限制在停滞或无穷的迭代器上花费的时间。若要加总为另一类型，请使用 ReduceCtx 函数。
+func (it Iter[T]) ReduceCtx(ctx context.Context, init T, fn func(T, T) T) (T, error) {
+	return ReduceCtx(ctx, it, init, fn)
+}
+
+// ReduceCtx is like the Reduce function, but gives up when ctx is done, like the ReduceCtx method.
+// A ctx that is already done returns init at once.
+//
+// ReduceCtx 函数与 Reduce 函数相同，但与 ReduceCtx 方法一样，在 ctx 结束时放弃。若 ctx 已经结束，则立即返回 init。
+func ReduceCtx[T, A any](ctx context.Context, it Iter[T], init A, fn func(A, T) A) (A, error) {
+	acc := init
+	for {
+		// checking ctx first makes it win over an element that is also ready
+		if err := ctx.Err(); err != nil {
+			it.Close()
+			return acc, err
+		}
+		select {
+		case x, ok := <-it:
+			if !ok {
+				return acc, nil
+			}
+			acc = fn(acc, x)
+		case <-ctx.Done():
+			it.Close()
+			return acc, ctx.Err()
+		}
+	}
+}
+
+// ReduceWhile is like Reduce, but fn also reports whether to go on. When fn returns false,
+// ReduceWhile stops at once and returns the accumulator including the contribution of that element,
+// and closes the Iter without consuming the rest of it. So it can be used on an infinite Iter as long as fn stops at some point.
+//
+// ReduceWhile 方法与 Reduce 相同，但 fn 还会返回是否继续。当 fn 返回 false 时，
+// ReduceWhile 立即停止，并返回包含该元素在内的加总结果，关闭迭代器而不消费其中剩余的元素。
+// 因此只要 fn 最终会停止，它就可以用于无穷迭代器。
+func (it Iter[T]) ReduceWhile(init T, fn func(acc, cur T) (T, bool)) T {
+	defer it.Close()
+	acc := init
+	for x := range it {
+		var more bool
+		acc, more = fn(acc, x)
+		if !more {
+			break
+		}
+	}
+	return acc
+}
+
+// TryReduce is like Reduce, but fn may fail. At the first error, TryReduce stops and closes the Iter
+// without consuming the rest of it, and returns the error along with the accumulator as it was before the failing element.
+//
+// TryReduce 方法与 Reduce 相同，但 fn 可能会出错。遇到第一个错误时，TryReduce 立即停止，
+// 关闭迭代器而不消费其中剩余的元素，并返回该错误，以及出错元素之前的加总结果。
+func (it Iter[T]) TryReduce(init T, fn func(acc, cur T) (T, error)) (T, error) {
+	defer it.Close()
+	acc := init
+	for x := range it {
+		next, err := fn(acc, x)
+		if err != nil {
+			return acc, err
+		}
+		acc = next
+	}
+	return acc, nil
+}
+
+// TryForEach is like ForEach, but fn may fail. At the first error, TryForEach stops and closes the Iter
+// without consuming the rest of it and returns the error.
+//
+// TryForEach 方法与 ForEach 相同，但 fn 可能会出错。遇到第一个错误时，TryForEach 立即停止，
+// 关闭迭代器而不消费其中剩余的元素，并返回该错误。
+func (it Iter[T]) TryForEach(fn func(T) error) error {
+	defer it.Close()
+	for x := range it {
+		if err := fn(x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Range generates an Iter containing integers [from, to).
+// If from >= to, the Iter is empty and has already ended, without a goroutine behind it.
 //
-// Range 方法生成一个包含 [from, to) 区间中整数的迭代器。
-func Range(from, to int) Iter {
-	ch := make(chan int)
+// Range 函数生成一个包含 [from, to) 区间中整数的迭代器。
+// 若 from >= to，迭代器为空并且已经结束，其背后没有 goroutine。
+func Range(from, to int) Iter[int] {
+	if from >= to {
+		return closedIter[int]()
+	}
+	ch, s := newSizedStage[int](rangeSize(from, to, 1), true)
+	s.rng = &RangeState{from, to, 1}
 	go func() {
-		defer close(ch)
+		defer s.finish()
 		for i := from; i < to; i++ {
-			ch <- i
+			if !send(s, ch, i) {
+				return
+			}
 		}
 	}()
 	return ch
 }
 
-// Seq creates an infinite Iter containing integers starting from 0
+// RangeInclusive generates an Iter containing integers [from, to].
+// The Iter is empty if from > to.
 //
-// Seq 方法生成包含从0开始的整数的无穷迭代器。
-func Seq() Iter {
-	ch := make(chan int)
-	n := 0
+// RangeInclusive 函数生成一个包含 [from, to] 区间中整数的迭代器。若 from > to，迭代器为空。
+func RangeInclusive(from, to int) Iter[int] {
+	if from > to {
+		return closedIter[int]()
+	}
+	size := rangeSize(from, to, 1)
+	if size >= 0 && size < math.MaxInt {
+		size++
+	} else {
+		size = -1
+	}
+	ch, s := newSizedStage[int](size, true)
+	if to < math.MaxInt {
+		s.rng = &RangeState{from, to + 1, 1}
+	}
+	go func() {
+		defer s.finish()
+		// checking i == to before incrementing avoids overflowing when to is math.MaxInt
+		for i := from; ; i++ {
+			if !send(s, ch, i) {
+				return
+			}
+			if i == to {
+				break
+			}
+		}
+	}()
+	return ch
+}
+
+// RangeStep generates an Iter containing integers from, from+step, from+2*step, ...
+// If step is positive, the elements are smaller than to; if step is negative, they are larger than to.
+// The Iter stops instead of wrapping around when the next element would overflow int.
+// RangeStep panics if step is zero.
+//
+// RangeStep 函数生成一个包含 from, from+step, from+2*step, ... 的迭代器。
+// 若 step 为正数，元素均小于 to；若 step 为负数，元素均大于 to。
+// 当下一个元素会导致 int 溢出时，迭代器会结束而不会回绕。step 为 0 时此函数会 panic。
+func RangeStep(from, to, step int) Iter[int] {
+	if step == 0 {
+		panic("RangeStep: step must not be zero")
+	}
+	ch, s := newSizedStage[int](rangeSize(from, to, step), true)
+	s.rng = &RangeState{from, to, step}
+	go func() {
+		defer s.finish()
+		for i := from; (step > 0 && i < to) || (step < 0 && i > to); i += step {
+			if !send(s, ch, i) {
+				return
+			}
+			if (step > 0 && i > math.MaxInt-step) || (step < 0 && i < math.MinInt-step) {
+				break
+			}
+		}
+	}()
+	return ch
+}
+
+// RangeCtx is like Range, but the Iter ends when ctx is done, as if it were wrapped with WithContext.
+//
+// RangeCtx 函数与 Range 相同，但当 ctx 结束时迭代器结束，相当于使用 WithContext 包装。
+func RangeCtx(ctx context.Context, from, to int) Iter[int] {
+	return Range(from, to).WithContext(ctx)
+}
+
+// Seq creates an Iter containing integers starting from 0.
+// It is infinite for all practical purposes, but ends after math.MaxInt instead of wrapping around.
+//
+// Seq 函数生成包含从0开始的整数的迭代器。它实际上是无穷的，但在 math.MaxInt 之后会结束，而不会回绕。
+func Seq() Iter[int] {
+	return Arithmetic(0, 1)
+}
+
+// SeqCtx is like Seq, but the Iter ends when ctx is done, as if it were wrapped with WithContext.
+//
+// SeqCtx 函数与 Seq 相同，但当 ctx 结束时迭代器结束，相当于使用 WithContext 包装。
+func SeqCtx(ctx context.Context) Iter[int] {
+	return Seq().WithContext(ctx)
+}
+
+// SeqFrom creates an infinite Iter containing integers start, start+step, start+2*step, ...
+// A negative step makes a descending sequence. Like Seq, the Iter ends instead of wrapping around
+// when the next element would overflow int.
+// SeqFrom panics if step is zero.
+//
+// SeqFrom 函数生成包含 start, start+step, start+2*step, ... 的无穷迭代器。
+// step 为负数时生成递减的序列。与 Seq 一样，当下一个元素会导致 int 溢出时，迭代器会结束而不会回绕。
+// step 为 0 时此函数会 panic。
+func SeqFrom(start, step int) Iter[int] {
+	if step == 0 {
+		panic("SeqFrom: step must not be zero")
+	}
+	return Arithmetic(start, step)
+}
+
+// Repeat creates an infinite Iter whose elements are all x.
+// The Iter never ends, so only consume it after bounding it with Take.
+//
+// Repeat 函数生成一个所有元素都是 x 的无穷迭代器。
+// 它永远不会结束，因此只应在使用 Take 截取后再进行消费。
+func Repeat[T any](x T) Iter[T] {
+	ch, s := newUnboundedStage[T]()
 	go func() {
+		defer s.finish()
 		for {
-			ch <- n
-			n++
+			if !send(s, ch, x) {
+				return
+			}
 		}
 	}()
 	return ch
 }
 
-// Take creates an Iter that only contains the first at most n elements of the original Iter.
+// RepeatN creates an Iter containing exactly n copies of x. The Iter is empty if n <= 0.
 //
-// Take 方法创建一个新的迭代器，只包含原先迭代器中的最多前 n 个元素。
-func (it Iter) Take(n int) Iter {
-	count := 0
-	ch := make(chan int)
+// RepeatN 函数生成一个包含 n 个 x 的迭代器。若 n <= 0，迭代器为空。
+func RepeatN[T any](x T, n int) Iter[T] {
+	if n <= 0 {
+		return closedIter[T]()
+	}
+	ch, s := newSizedStage[T](n, true)
 	go func() {
-		defer close(ch)
-		for x := range it {
-			if count < n {
-				ch <- x
-				count++
-			} else {
+		defer s.finish()
+		for i := 0; i < n; i++ {
+			if !send(s, ch, x) {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// FromChan creates an Iter forwarding the elements received from ch, which ends when the producer closes ch.
+// Closing the Iter stops forwarding, but does not close ch, which belongs to the producer.
+//
+// FromChan 函数生成一个转发 ch 中元素的迭代器，当生产者关闭 ch 时，迭代器结束。
+// 关闭迭代器会停止转发，但不会关闭 ch，因为 ch 属于生产者。
+func FromChan[T any](ch <-chan T) Iter[T] {
+	return FromChanDrain(ch, math.MaxInt)
+}
+
+// FromChanDrain creates an Iter forwarding the elements received from ch,
+// which ends when ch is closed or after max elements, whichever comes first.
+// It protects Collect and Reduce from channels that are never closed.
+//
+// FromChanDrain 函数生成一个转发 ch 中元素的迭代器。当 ch 被关闭，或已转发 max 个元素时，迭代器结束。
+// 它可以避免在永不关闭的 channel 上调用 Collect 或 Reduce 导致死循环。
+func FromChanDrain[T any](ch <-chan T, max int) Iter[T] {
+	out, s := newStage[T]()
+	go func() {
+		defer s.finish()
+		for count := 0; count < max; count++ {
+			var x T
+			var ok bool
+			select {
+			case x, ok = <-ch:
+			case <-s.done:
+				return
+			}
+			if !ok || !send(s, out, x) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// FromFunc creates an Iter by calling next repeatedly, emitting the values while next reports true.
+// The Iter ends at the first false, after which next is never called again.
+// If next panics, the Iter is closed and the panic is re-raised in the producing goroutine;
+// use FromFuncRecover to handle it instead.
+//
+// FromFunc 函数反复调用 next 来生成迭代器，当 next 返回 true 时发送其返回值。
+// 当 next 第一次返回 false 时迭代器结束，此后不会再调用 next。
+// 若 next 发生 panic，迭代器会被关闭，并且 panic 会在生成元素的 goroutine 中被重新抛出；
+// 如需处理 panic，请使用 FromFuncRecover。
+func FromFunc[T any](next func() (T, bool)) Iter[T] {
+	return FromFuncRecover(next, nil)
+}
+
+// FromFuncRecover is like FromFunc, but if next panics, the Iter is closed and
+// the recovered value is passed to onPanic. A nil onPanic re-raises the panic.
+//
+// FromFuncRecover 函数与 FromFunc 相同，但若 next 发生 panic，迭代器会被关闭，
+// 并将 recover 得到的值传给 onPanic。若 onPanic 为 nil，则重新抛出 panic。
+func FromFuncRecover[T any](next func() (T, bool), onPanic func(interface{})) Iter[T] {
+	ch, s := newStage[T]()
+	go func() {
+		defer func() {
+			s.finish()
+			if r := recover(); r != nil {
+				if onPanic == nil {
+					panic(r)
+				}
+				onPanic(r)
+			}
+		}()
+		for {
+			x, ok := next()
+			if !ok {
 				break
 			}
+			if !send(s, ch, x) {
+				return
+			}
 		}
 	}()
 	return ch
 }
 
-// Drop creates an Iter that skips over the first at most n elements of the original Iter.
+// FromSeq creates an Iter of the values yielded by seq, a range-over-func iterator of the standard library.
+// seq is ranged over in a new goroutine once, and stops as soon as the Iter is closed.
 //
-// Drop 方法创建一个新的迭代器，跳过原先迭代器中的最多前 n 个元素。
-func (it Iter) Drop(n int) Iter {
-	count := 0
-	ch := make(chan int)
+// FromSeq 函数生成一个迭代器，包含标准库中的 range-over-func 迭代器 seq 产生的值。
+// seq 会在一个新的 goroutine 中被遍历一次，一旦迭代器被关闭，遍历即停止。
+func FromSeq[T any](seq iter.Seq[T]) Iter[T] {
+	ch, s := newStage[T]()
 	go func() {
-		defer close(ch)
-		for x := range it {
-			if count < n {
-				count++
-			} else {
-				ch <- x
+		defer s.finish()
+		for x := range seq {
+			if !send(s, ch, x) {
+				return
 			}
 		}
 	}()
 	return ch
 }
 
-// Collect turns an Iter to a slice.
-// DO NOT call this method on an infinite Iter, or it results in an infinite loop.
+// FromReader creates an Iter of the whitespace-separated integers read from r.
+// The Iter ends at EOF, at the first read error, or at the first token that is not an integer.
 //
-// Collect 方法将一个迭代器转化成一个 slice。
-// 不要在无穷迭代器上调用此方法，否则会导致死循环。
-func (it Iter) Collect() []int {
-	var s []int
-	for x := range it {
-		s = append(s, x)
+// FromReader 函数生成一个迭代器，包含从 r 中读取的以空白分隔的整数。
+// 当读到 EOF、发生读取错误，或遇到第一个不是整数的词时，迭代器结束。
+func FromReader(r io.Reader) Iter[int] {
+	return FromReaderFunc(r, func(string, error) bool { return false })
+}
+
+// FromReaderFunc is like FromReader, but calls onErr with every token that is not an integer.
+// If onErr returns true, the token is skipped; otherwise the Iter ends.
+//
+// FromReaderFunc 函数与 FromReader 相同，但遇到不是整数的词时会调用 onErr。
+// 若 onErr 返回 true，则跳过该词；否则迭代器结束。
+func FromReaderFunc(r io.Reader, onErr func(token string, err error) (skip bool)) Iter[int] {
+	ch, s := newStage[int]()
+	go func() {
+		defer s.finish()
+		scanner := bufio.NewScanner(r)
+		scanner.Split(bufio.ScanWords)
+		for scanner.Scan() {
+			token := scanner.Text()
+			x, err := strconv.Atoi(token)
+			if err != nil {
+				if onErr(token, err) {
+					continue
+				}
+				break
+			}
+			if !send(s, ch, x) {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// FromBinary creates an Iter of the signed varints (as encoded by binary.PutVarint) read from r.
+// The Iter ends at EOF or at the first error, including truncated trailing bytes.
+//
+// FromBinary 函数生成一个迭代器，包含从 r 中读取的有符号 varint（即 binary.PutVarint 的编码）。
+// 当读到 EOF 或发生错误（包括末尾字节不完整）时，迭代器结束。
+func FromBinary(r io.Reader) Iter[int] {
+	return FromBinaryFunc(r, func(error) {})
+}
+
+// FromBinaryFunc is like FromBinary, but calls onErr with the error that ends the Iter,
+// e.g. io.ErrUnexpectedEOF for truncated trailing bytes. onErr is not called at a clean EOF.
+//
+// FromBinaryFunc 函数与 FromBinary 相同，但会将导致迭代器结束的错误传给 onErr，
+// 例如末尾字节不完整时的 io.ErrUnexpectedEOF。正常读到 EOF 时不会调用 onErr。
+func FromBinaryFunc(r io.Reader, onErr func(error)) Iter[int] {
+	ch, s := newStage[int]()
+	go func() {
+		defer s.finish()
+		br, ok := r.(io.ByteReader)
+		if !ok {
+			br = bufio.NewReader(r)
+		}
+		for {
+			x, err := binary.ReadVarint(br)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				onErr(err)
+				break
+			}
+			if !send(s, ch, int(x)) {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// FromJSON creates an Iter of the integers in the top-level JSON array read by dec.
+// The elements are decoded lazily, one at a time, so the array never has to fit in memory.
+// The Iter ends at the closing bracket or at the first error, e.g. when the input is not an array,
+// or an element is not an integer. FromJSON calls dec.UseNumber so that large integers keep their precision.
+//
+// FromJSON 函数生成一个迭代器，包含 dec 读取的顶层 JSON 数组中的整数。
+// 元素是逐个延迟解码的，因此整个数组不需要全部放入内存。
+// 当读到数组的右括号，或发生错误（例如输入不是数组，或某个元素不是整数）时，迭代器结束。
+// FromJSON 会调用 dec.UseNumber，以保证大整数的精度。
+func FromJSON(dec *json.Decoder) Iter[int] {
+	return FromJSONFunc(dec, func(error) {})
+}
+
+// FromJSONFunc is like FromJSON, but calls onErr with the error that ends the Iter.
+// onErr is not called when the array ends normally.
+//
+// FromJSONFunc 函数与 FromJSON 相同，但会将导致迭代器结束的错误传给 onErr。数组正常结束时不会调用 onErr。
+func FromJSONFunc(dec *json.Decoder, onErr func(error)) Iter[int] {
+	ch, s := newStage[int]()
+	go func() {
+		defer s.finish()
+		dec.UseNumber()
+		tok, err := dec.Token()
+		if err != nil {
+			onErr(err)
+			return
+		}
+		if tok != json.Delim('[') {
+			onErr(fmt.Errorf("FromJSON: expecting an array, got %v", tok))
+			return
+		}
+		for {
+			tok, err := dec.Token()
+			if err != nil {
+				onErr(err)
+				return
+			}
+			if tok == json.Delim(']') {
+				return
+			}
+			n, ok := tok.(json.Number)
+			if !ok {
+				onErr(fmt.Errorf("FromJSON: expecting an integer, got %v", tok))
+				return
+			}
+			x, err := strconv.Atoi(n.String())
+			if err != nil {
+				onErr(fmt.Errorf("FromJSON: expecting an integer, got %v", n))
+				return
+			}
+			if !send(s, ch, x) {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// FromCSV creates an Iter of the integers in column col (zero-based) of the records read from r.
+// The records are read lazily. The Iter ends at EOF or at the first record that cannot be read,
+// is too short, or has a column that is not an integer. A header row must be read by the caller
+// beforehand, e.g. with r.Read().
+//
+// FromCSV 函数生成一个迭代器，包含从 r 中读取的记录里第 col 列（从 0 开始）的整数。记录是延迟读取的。
+// 当读到 EOF，或遇到第一条无法读取、字段不足或该列不是整数的记录时，迭代器结束。
+// 若有标题行，需要调用者事先读取，例如调用 r.Read()。
+func FromCSV(r *csv.Reader, col int) Iter[int] {
+	return FromCSVFunc(r, col, func(int, error) bool { return false })
+}
+
+// FromCSVFunc is like FromCSV, but calls onErr with the line number and the error of every bad record.
+// If onErr returns true, the record is skipped; otherwise the Iter ends.
+//
+// FromCSVFunc 函数与 FromCSV 相同，但遇到有问题的记录时，会将其行号和错误传给 onErr。
+// 若 onErr 返回 true，则跳过该记录；否则迭代器结束。
+func FromCSVFunc(r *csv.Reader, col int, onErr func(line int, err error) bool) Iter[int] {
+	ch, s := newStage[int]()
+	go func() {
+		defer s.finish()
+		for {
+			record, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			var x int
+			if err == nil {
+				if col < len(record) {
+					x, err = strconv.Atoi(record[col])
+				} else {
+					err = fmt.Errorf("FromCSV: record has %d fields, expecting at least %d", len(record), col+1)
+				}
+			}
+			if err != nil {
+				var line int
+				var parseErr *csv.ParseError
+				if errors.As(err, &parseErr) {
+					line = parseErr.StartLine
+				} else {
+					line, _ = r.FieldPos(0)
+				}
+				if onErr(line, err) {
+					continue
+				}
+				break
+			}
+			if !send(s, ch, x) {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// Random creates an infinite Iter of pseudo-random integers uniformly distributed in [lo, hi), drawn from rng.
+// The elements are drawn in a separate goroutine, and *rand.Rand is not safe for concurrent use,
+// so rng must not be used anywhere else while the Iter is alive. Any lo < hi is allowed, even a range wider than
+// the largest int, such as [math.MinInt, math.MaxInt). Random panics if hi <= lo.
+//
+// Random 函数生成一个无穷迭代器，包含从 rng 中抽取的、在 [lo, hi) 区间中均匀分布的伪随机整数。
+// 元素是在另一个 goroutine 中抽取的，而 *rand.Rand 不是并发安全的，
+// 因此在迭代器存活期间，不要在其他地方使用 rng。任何 lo < hi 都是允许的，即使区间宽于最大的 int，
+// 例如 [math.MinInt, math.MaxInt)。若 hi <= lo，此函数会 panic。
+func Random(rng *rand.Rand, lo, hi int) Iter[int] {
+	if hi <= lo {
+		panic("Random: hi must be greater than lo")
+	}
+	// hi-lo overflows an int for a wide range, but not as an unsigned span
+	span := uint64(hi) - uint64(lo)
+	draw := func() int {
+		if span <= math.MaxInt {
+			return lo + rng.Intn(int(span))
+		}
+		// the span is more than half of the uint64s, so a uniform uint64 is accepted more than half of the time
+		for {
+			if v := rng.Uint64(); v < span {
+				return int(uint64(lo) + v)
+			}
+		}
+	}
+	ch, s := newUnboundedStage[int]()
+	go func() {
+		defer s.finish()
+		for {
+			if !send(s, ch, draw()) {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// Primes creates an infinite Iter containing the prime numbers in ascending order.
+// It uses an incremental sieve of Eratosthenes: every prime found so far is filed under its next multiple,
+// so each number is only checked against its own prime factors.
+//
+// Primes 函数生成一个按升序包含所有质数的无穷迭代器。
+// 它使用增量式的埃拉托斯特尼筛法：每个已找到的质数都被记录在它的下一个倍数之下，
+// 因此每个数只需要与它自己的质因数进行比较。
+func Primes() Iter[int] {
+	ch, s := newUnboundedStage[int]()
+	go func() {
+		defer s.finish()
+		// composites maps each upcoming composite number to the primes that divide it
+		composites := make(map[int][]int)
+		for n := 2; ; n++ {
+			factors, ok := composites[n]
+			if !ok {
+				if !send(s, ch, n) {
+					return
+				}
+				composites[n*n] = []int{n}
+				continue
+			}
+			for _, p := range factors {
+				composites[n+p] = append(composites[n+p], p)
+			}
+			delete(composites, n)
+		}
+	}()
+	return ch
+}
+
+// Arithmetic creates an Iter containing the arithmetic sequence start, start+step, start+2*step, ...
+// Like Seq, the Iter is infinite unless the next element would overflow int, in which case it ends there.
+// Unless the overflow is at most math.MaxInt32 elements away, which also gives the Iter an exact size hint,
+// it is too far away to be reached in practice, so the Iter is reported as Unbounded.
+//
+// Arithmetic 函数生成一个包含等差数列 start, start+step, start+2*step, ... 的迭代器。
+// 与 Seq 一样，除非下一个元素会导致 int 溢出（此时迭代器结束），否则迭代器是无穷的。
+// 除非溢出之处在 math.MaxInt32 个元素以内（此时迭代器也有确切的大小提示），否则实际上永远不会到达该处，
+// 因此迭代器被报告为无界的（见 Unbounded）。
+func Arithmetic(start, step int) Iter[int] {
+	var ch chan int
+	var s *stage
+	if n := arithmeticLen(start, step); n >= 0 && n <= math.MaxInt32 {
+		ch, s = newSizedStage[int](n, true)
+	} else {
+		ch, s = newUnboundedStage[int]()
+	}
+	go func() {
+		defer s.finish()
+		for x := start; ; x += step {
+			if !send(s, ch, x) || addOverflows(x, step) {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// arithmeticLen returns the number of elements of Arithmetic(start, step) up to int overflow,
+// or -1 if step is zero or the number overflows an int itself.
+func arithmeticLen(start, step int) int {
+	// the distance to the end of the int range and the step wrap around as ints, but are right as uints
+	var d, stride uint
+	switch {
+	case step > 0:
+		d, stride = uint(math.MaxInt-start), uint(step)
+	case step < 0:
+		d, stride = uint(start-math.MinInt), uint(-step)
+	default:
+		return -1
+	}
+	if n := d/stride + 1; n <= math.MaxInt {
+		return int(n)
+	}
+	return -1
+}
+
+// Geometric creates an Iter containing the geometric sequence start, start*ratio, start*ratio^2, ...
+// The Iter is infinite unless the next element would overflow int, in which case it ends there.
+//
+// Geometric 函数生成一个包含等比数列 start, start*ratio, start*ratio^2, ... 的迭代器。
+// 除非下一个元素会导致 int 溢出（此时迭代器结束），否则迭代器是无穷的。
+func Geometric(start, ratio int) Iter[int] {
+	ch, s := newStage[int]()
+	go func() {
+		defer s.finish()
+		for x := start; ; x *= ratio {
+			if !send(s, ch, x) {
+				return
+			}
+			if mulOverflows(x, ratio) {
+				break
+			}
+		}
+	}()
+	return ch
+}
+
+// mulOverflows reports whether a*b overflows int.
+func mulOverflows(a, b int) bool {
+	if a == 0 || b == 0 {
+		return false
+	}
+	c := a * b
+	return c/b != a || (a == -1 && b == math.MinInt) || (b == -1 && a == math.MinInt)
+}
+
+// Digits creates an Iter containing the base-10 digits of n, the most significant digit first.
+// Zero has the single digit 0, and a negative n has the same digits as its absolute value.
+//
+// Digits 函数生成一个迭代器，从最高位开始包含 n 的十进制各位数字。
+// 0 只有一位数字 0；负数 n 的各位数字与其绝对值相同。
+func Digits(n int) Iter[int] {
+	return DigitsBase(n, 10)
+}
+
+// DigitsBase is like Digits, but uses the given base. DigitsBase panics if base < 2.
+//
+// DigitsBase 函数与 Digits 相同，但使用给定的进制 base。若 base < 2，此函数会 panic。
+func DigitsBase(n, base int) Iter[int] {
+	if base < 2 {
+		panic("DigitsBase: base must be at least 2")
+	}
+	ch, s := newStage[int]()
+	go func() {
+		defer s.finish()
+		// using uint64 keeps the absolute value of math.MinInt representable
+		u, b := uint64(n), uint64(base)
+		if n < 0 {
+			u = -u
+		}
+		p := uint64(1)
+		for p <= u/b {
+			p *= b
+		}
+		for ; p > 0; p /= b {
+			if !send(s, ch, int(u/p)) {
+				return
+			}
+			u %= p
+		}
+	}()
+	return ch
+}
+
+// Bits creates an Iter containing the positions of the set bits of n in ascending order,
+// e.g. Bits(0b10110) contains 1, 2, 4. It is the inverse of ToBits.
+//
+// Bits 函数生成一个迭代器，按升序包含 n 中为 1 的二进制位的位置，
+// 例如 Bits(0b10110) 包含 1, 2, 4。它是 ToBits 的逆操作。
+func Bits(n uint64) Iter[int] {
+	ch, s := newStage[int]()
+	go func() {
+		defer s.finish()
+		for n != 0 {
+			i := bits.TrailingZeros64(n)
+			if !send(s, ch, i) {
+				return
+			}
+			n &^= 1 << uint(i)
+		}
+	}()
+	return ch
+}
+
+// Tick creates an infinite Iter containing integers 0, 1, 2, ..., one per interval d, driven by a time.Ticker.
+// The ticker is stopped when the Iter is closed.
+//
+// Tick 函数生成一个包含 0, 1, 2, ... 的无穷迭代器，由 time.Ticker 驱动，每隔 d 产生一个元素。
+// 当迭代器被关闭时，其中的 ticker 会停止。
+func Tick(d time.Duration) Iter[int] {
+	return TickCtx(context.Background(), d)
+}
+
+// TickCtx is like Tick, but the Iter ends and the ticker is stopped when ctx is done.
+//
+// TickCtx 函数与 Tick 相同，但当 ctx 结束时，迭代器结束并停止 ticker。
+func TickCtx(ctx context.Context, d time.Duration) Iter[int] {
+	ch, s := newStage[int]()
+	// a context that is never done, such as context.Background(), never ends the Iter
+	s.unbounded = ctx.Done() == nil
+	go func() {
+		defer s.finish()
+		ticker := time.NewTicker(d)
+		defer ticker.Stop()
+		for n := 0; ; n++ {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			case <-s.done:
+				return
+			}
+			select {
+			case ch <- n:
+			case <-ctx.Done():
+				return
+			case <-s.done:
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// FromSlices creates an Iter containing the elements of every slice in batches, one slice after another.
+// Empty and nil slices contribute nothing.
+//
+// FromSlices 函数生成一个迭代器，依次包含 batches 中每个 slice 的元素。空的或为 nil 的 slice 不产生元素。
+func FromSlices[T any](batches [][]T) Iter[T] {
+	size := 0
+	for _, batch := range batches {
+		size += len(batch)
+	}
+	ch, s := newSizedStage[T](size, true)
+	go func() {
+		defer s.finish()
+		for _, batch := range batches {
+			for _, x := range batch {
+				if !send(s, ch, x) {
+					return
+				}
+			}
+		}
+	}()
+	return ch
+}
+
+// WithContext creates an Iter forwarding the elements of the original Iter until ctx is done.
+// Then it stops forwarding, closes the new Iter so that a for range loop over it exits, and closes the original Iter,
+// releasing the goroutines of the whole pipeline. An element that is already being received when ctx is cancelled
+// may still be delivered, but no element is sent once the cancellation has been observed.
+//
+// WithContext 方法生成一个新的迭代器，转发原先迭代器中的元素，直到 ctx 结束。
+// 此后它停止转发，关闭新的迭代器，使遍历它的 for range 循环退出，并关闭原先的迭代器，释放整个流水线中的 goroutine。
+// 在 ctx 被取消时正在被接收的元素仍可能被送达，但在观察到取消之后，不会再发送任何元素。
+func (it Iter[T]) WithContext(ctx context.Context) Iter[T] {
+	// the context may end the Iter early, so its size is only an upper bound
+	size, _ := it.SizeHint()
+	ch, s := newSizedStage[T](size, false, it)
+	go func() {
+		defer s.finish()
+		for {
+			var x T
+			var ok bool
+			select {
+			case x, ok = <-it:
+			case <-ctx.Done():
+				return
+			}
+			if !ok || ctx.Err() != nil {
+				return
+			}
+			select {
+			case ch <- x:
+			case <-ctx.Done():
+				return
+			case <-s.done:
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// Take creates an Iter that only contains the first at most n elements of the original Iter.
+// It receives exactly min(n, length) elements from the original Iter, and then closes it.
+// Take(0) receives nothing and returns an Iter that has already ended. Take panics if n is negative.
+//
+// Take 方法创建一个新的迭代器，只包含原先迭代器中的最多前 n 个元素。
+// 它从原先的迭代器中恰好接收 min(n, 长度) 个元素，然后将其关闭。Take(0) 不接收任何元素，并返回一个已经结束的迭代器。
+// 若 n 为负数，此方法会 panic。
+func (it Iter[T]) Take(n int) Iter[T] {
+	if n < 0 {
+		panic("Take: n must not be negative")
+	}
+	if n == 0 {
+		it.Close()
+		return closedIter[T]()
+	}
+	size, exact := n, false
+	if m, e := it.SizeHint(); m >= 0 {
+		size, exact = min(n, m), e
+	}
+	ch, s := newSizedStage[T](size, exact, it)
+	go func() {
+		defer s.finish()
+		// checking the count before receiving avoids taking an extra element from the original Iter
+		for count := 0; count < n; count++ {
+			x, ok := <-it
+			if !ok || !send(s, ch, x) {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// Drop creates an Iter that skips over the first at most n elements of the original Iter.
+// Drop(0) returns the original Iter itself. Drop panics if n is negative.
+//
+// Drop 方法创建一个新的迭代器，跳过原先迭代器中的最多前 n 个元素。Drop(0) 直接返回原先的迭代器。
+// 若 n 为负数，此方法会 panic。
+func (it Iter[T]) Drop(n int) Iter[T] {
+	if n < 0 {
+		panic("Drop: n must not be negative")
+	}
+	if n == 0 {
+		return it
+	}
+	count := 0
+	size, exact := it.SizeHint()
+	if size >= 0 {
+		size = max(size-n, 0)
+	}
+	ch, s := newSizedStage[T](size, exact, it)
+	s.unbounded = it.Unbounded()
+	go func() {
+		defer s.finish()
+		for x := range it {
+			if count < n {
+				count++
+			} else if !send(s, ch, x) {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// collectPrealloc is the most bytes Collect allocates up front from an exact SizeHint.
+// The hint may be far more than the elements left, e.g. of a partly consumed Range, so beyond it append grows the slice.
+const collectPrealloc = 4 << 20
+
+// collectCap returns the capacity Collect pre-allocates for an exact SizeHint of n elements of type T.
+// It also bounds the other buffers sized up front from a number the caller passes, such as the heap of SortedWithinBy.
+func collectCap[T any](n int) int {
+	var zero T
+	if size := int(unsafe.Sizeof(zero)); size > 0 && n > collectPrealloc/size {
+		return collectPrealloc / size
+	}
+	return n
+}
+
+// Collect turns an Iter to a slice. It pre-allocates the slice from an exact SizeHint, but no more than 4 MiB of it.
+// DO NOT call this method on an infinite Iter, or it results in an infinite loop;
+// it panics at once on an Iter that is known to be Unbounded.
+//
+// Collect 方法将一个迭代器转化成一个 slice。若 SizeHint 是精确的，它会据此预先分配 slice，但最多分配 4 MiB。
+// 不要在无穷迭代器上调用此方法，否则会导致死循环；在已知无界（见 Unbounded）的迭代器上它会立即 panic。
+func (it Iter[T]) Collect() []T {
+	mustBeBounded("Collect", it)
+	var s []T
+	n, exact := it.SizeHint()
+	for x := range it {
+		if s == nil && exact {
+			s = make([]T, 0, collectCap[T](n))
+		}
+		s = append(s, x)
+	}
+	return s
+}
+
+// CollectCtx is like Collect, but gives up when ctx is done, returning the elements received so far
+// together with ctx.Err(), and closing the Iter without consuming the rest of it.
+//
+// CollectCtx 方法与 Collect 相同，但在 ctx 结束时放弃，返回目前为止接收到的元素以及 ctx.Err()，
+// 并关闭迭代器而不消费剩余的元素。
+func (it Iter[T]) CollectCtx(ctx context.Context) ([]T, error) {
+	return ReduceCtx(ctx, it, []T(nil), func(s []T, x T) []T { return append(s, x) })
+}
+
+// CollectWithTimeout is like CollectCtx with a context that times out after d.
+//
+// CollectWithTimeout 方法与 CollectCtx 相同，使用的 context 在 d 之后超时。
+func (it Iter[T]) CollectWithTimeout(d time.Duration) ([]T, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return it.CollectCtx(ctx)
+}
+
+// ToBits sets the bits of a uint64 at the positions given by the elements of the Iter.
+// It is the inverse of Bits. ToBits panics if a position is not in [0, 64).
+// DO NOT call this function on an infinite Iter, or it results in an infinite loop.
+//
+// ToBits 函数将一个 uint64 中位置为迭代器元素的二进制位设为 1。它是 Bits 的逆操作。
+// 若某个位置不在 [0, 64) 区间中，此函数会 panic。
+// 不要在无穷迭代器上调用此函数，否则会导致死循环。
+func ToBits(it Iter[int]) uint64 {
+	defer it.Close()
+	var n uint64
+	for x := range it {
+		if x < 0 || x >= 64 {
+			panic(fmt.Sprintf("ToBits: bit position %d out of range [0, 64)", x))
+		}
+		n |= 1 << uint(x)
+	}
+	return n
+}
+
+// Count returns the number of elements in the Iter, without collecting them.
+// DO NOT call this method on an infinite Iter, or it results in an infinite loop;
+// it panics at once on an Iter that is known to be Unbounded.
+//
+// Count 方法返回迭代器中元素的个数，不会将元素收集起来。
+// 不要在无穷迭代器上调用此方法，否则会导致死循环；在已知无界（见 Unbounded）的迭代器上它会立即 panic。
+func (it Iter[T]) Count() int {
+	mustBeBounded("Count", it)
+	n := 0
+	for range it {
+		n++
+	}
+	return n
+}
+
+// Number is the constraint of the element types that Sum, Product and Median can do arithmetic on.
+//
+// Number 是 Sum、Product 和 Median 可以进行算术运算的元素类型的约束。
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// Sum returns the sum of the elements in the Iter, or 0 if it is empty. The sum wraps around on overflow.
+// DO NOT call this function on an infinite Iter, or it results in an infinite loop.
+//
+// Sum 函数返回迭代器中所有元素的和，若迭代器为空则返回 0。和在溢出时会回绕。
+// 不要在无穷迭代器上调用此函数，否则会导致死循环。
+func Sum[T Number](it Iter[T]) T {
+	return it.Reduce(0, func(acc, cur T) T { return acc + cur })
+}
+
+// Product returns the product of the elements in the Iter, or 1 if it is empty. The product wraps around on overflow.
+// DO NOT call this function on an infinite Iter, or it results in an infinite loop.
+//
+// Product 函数返回迭代器中所有元素的积，若迭代器为空则返回 1。积在溢出时会回绕。
+// 不要在无穷迭代器上调用此函数，否则会导致死循环。
+func Product[T Number](it Iter[T]) T {
+	return it.Reduce(1, func(acc, cur T) T { return acc * cur })
+}
+
+// SumChecked is like Sum, but returns (0, false) as soon as the running sum overflows.
+//
+// SumChecked 函数与 Sum 相同，但一旦累加的和发生溢出，便立即返回 (0, false)。
+func SumChecked(it Iter[int]) (int, bool) {
+	defer it.Close()
+	acc := 0
+	for x := range it {
+		if addOverflows(acc, x) {
+			return 0, false
+		}
+		acc += x
+	}
+	return acc, true
+}
+
+// ProductChecked is like Product, but returns (0, false) as soon as the running product overflows.
+//
+// ProductChecked 函数与 Product 相同，但一旦累乘的积发生溢出，便立即返回 (0, false)。
+func ProductChecked(it Iter[int]) (int, bool) {
+	defer it.Close()
+	acc := 1
+	for x := range it {
+		if mulOverflows(acc, x) {
+			return 0, false
+		}
+		acc *= x
+	}
+	return acc, true
+}
+
+// addOverflows reports whether a+b overflows int.
+func addOverflows(a, b int) bool {
+	return (b > 0 && a > math.MaxInt-b) || (b < 0 && a < math.MinInt-b)
+}
+
+// Min returns the smallest element of the Iter, or the zero value and false if it is empty.
+// DO NOT call this function on an infinite Iter, or it results in an infinite loop.
+//
+// Min 函数返回迭代器中最小的元素；若迭代器为空，则返回 T 的零值和 false。
+// 不要在无穷迭代器上调用此函数，否则会导致死循环。
+func Min[T cmp.Ordered](it Iter[T]) (T, bool) {
+	min, _, ok := MinMax(it)
+	return min, ok
+}
+
+// Max returns the largest element of the Iter, or the zero value and false if it is empty.
+// DO NOT call this function on an infinite Iter, or it results in an infinite loop.
+//
+// Max 函数返回迭代器中最大的元素；若迭代器为空，则返回 T 的零值和 false。
+// 不要在无穷迭代器上调用此函数，否则会导致死循环。
+func Max[T cmp.Ordered](it Iter[T]) (T, bool) {
+	_, max, ok := MinMax(it)
+	return max, ok
+}
+
+// MinMax returns both the smallest and the largest elements of the Iter in a single pass,
+// or two zero values and false if it is empty.
+// DO NOT call this function on an infinite Iter, or it results in an infinite loop.
+//
+// MinMax 函数只遍历一次迭代器，同时返回其中最小和最大的元素；若迭代器为空，则返回两个零值和 false。
+// 不要在无穷迭代器上调用此函数，否则会导致死循环。
+func MinMax[T cmp.Ordered](it Iter[T]) (min, max T, ok bool) {
+	for x := range it {
+		if !ok {
+			min, max, ok = x, x, true
+			continue
+		}
+		if x < min {
+			min = x
+		}
+		if x > max {
+			max = x
+		}
+	}
+	return min, max, ok
+}
+
+// MinBy returns the element of the Iter with the smallest key, or the zero value and false if it is empty.
+// If several elements share the smallest key, the first one is returned.
+// DO NOT call this function on an infinite Iter, or it results in an infinite loop.
+//
+// MinBy 函数返回迭代器中 key 最小的元素；若迭代器为空，则返回 T 的零值和 false。
+// 若有多个元素的 key 同为最小，则返回其中第一个。
+// 不要在无穷迭代器上调用此函数，否则会导致死循环。
+func MinBy[T any, K cmp.Ordered](it Iter[T], key func(T) K) (T, bool) {
+	return bestBy(it, key, func(a, b K) bool { return a < b })
+}
+
+// MaxBy returns the element of the Iter with the largest key, or the zero value and false if it is empty.
+// If several elements share the largest key, the first one is returned.
+// DO NOT call this function on an infinite Iter, or it results in an infinite loop.
+//
+// MaxBy 函数返回迭代器中 key 最大的元素；若迭代器为空，则返回 T 的零值和 false。
+// 若有多个元素的 key 同为最大，则返回其中第一个。
+// 不要在无穷迭代器上调用此函数，否则会导致死循环。
+func MaxBy[T any, K cmp.Ordered](it Iter[T], key func(T) K) (T, bool) {
+	return bestBy(it, key, func(a, b K) bool { return a > b })
+}
+
+// bestBy returns the first element whose key is better than the keys of all the elements before it.
+func bestBy[T any, K cmp.Ordered](it Iter[T], key func(T) K, better func(a, b K) bool) (T, bool) {
+	var best T
+	var bestKey K
+	ok := false
+	for x := range it {
+		k := key(x)
+		if !ok || better(k, bestKey) {
+			best, bestKey, ok = x, k, true
+		}
+	}
+	return best, ok
+}
+
+// Summary holds the summary statistics of an Iter, as computed by Stats.
+// Variance and StdDev are the population variance and standard deviation.
+//
+// Summary 类型保存由 Stats 函数计算出的迭代器的统计信息。
+// Variance 和 StdDev 分别是总体方差和总体标准差。
+type Summary struct {
+	Count            int
+	Min, Max, Sum    int
+	Mean             float64
+	Variance, StdDev float64
+}
+
+// Stats computes the Summary of the Iter in a single pass, using Welford's algorithm for a numerically stable variance.
+// An empty Iter has a zero Summary, with Count 0 and every other field 0 as well. Sum wraps around on overflow.
+// DO NOT call this function on an infinite Iter, or it results in an infinite loop.
+//
+// Stats 函数只遍历一次迭代器，计算其 Summary，并使用 Welford 算法以保证方差计算的数值稳定性。
+// 空迭代器的 Summary 为零值，即 Count 为 0，其余字段也均为 0。Sum 在溢出时会回绕。
+// 不要在无穷迭代器上调用此函数，否则会导致死循环。
+func Stats(it Iter[int]) Summary {
+	var s Summary
+	var m2 float64
+	for x := range it {
+		if s.Count == 0 || x < s.Min {
+			s.Min = x
+		}
+		if s.Count == 0 || x > s.Max {
+			s.Max = x
+		}
+		s.Count++
+		s.Sum += x
+		delta := float64(x) - s.Mean
+		s.Mean += delta / float64(s.Count)
+		m2 += delta * (float64(x) - s.Mean)
+	}
+	if s.Count > 0 {
+		s.Variance = m2 / float64(s.Count)
+		s.StdDev = math.Sqrt(s.Variance)
+	}
+	return s
+}
+
+// Any reports whether any element of the Iter satisfies pred. It returns true at the first match
+// and closes the Iter without consuming the rest of it, so it can be used on an infinite Iter that has a match.
+// Any returns false for an empty Iter.
+//
+// Any 方法判断迭代器中是否有任一元素满足 pred。它在遇到第一个满足条件的元素时立即返回 true，
+// 关闭迭代器而不消费其中剩余的元素，因此可以用于存在满足条件元素的无穷迭代器。空迭代器返回 false。
+func (it Iter[T]) Any(pred func(T) bool) bool {
+	defer it.Close()
+	for x := range it {
+		if pred(x) {
+			return true
+		}
+	}
+	return false
+}
+
+// All reports whether every element of the Iter satisfies pred. It returns false at the first failure
+// and closes the Iter without consuming the rest of it. All returns true for an empty Iter.
+//
+// All 方法判断迭代器中是否所有元素都满足 pred。它在遇到第一个不满足条件的元素时立即返回 false，
+// 关闭迭代器而不消费其中剩余的元素。空迭代器返回 true。
+func (it Iter[T]) All(pred func(T) bool) bool {
+	return !it.Any(func(x T) bool { return !pred(x) })
+}
+
+// None reports whether no element of the Iter satisfies pred. It returns false at the first match
+// and closes the Iter without consuming the rest of it. None returns true for an empty Iter.
+//
+// None 方法判断迭代器中是否没有元素满足 pred。它在遇到第一个满足条件的元素时立即返回 false，
+// 关闭迭代器而不消费其中剩余的元素。空迭代器返回 true。
+func (it Iter[T]) None(pred func(T) bool) bool {
+	return !it.Any(pred)
+}
+
+// Find returns the first element of the Iter that satisfies pred, or the zero value and false if there is none.
+// It stops consuming the Iter and closes it at the first match, so it can be used on an infinite Iter that has a match.
+//
+// Find 方法返回迭代器中第一个满足 pred 的元素；若没有这样的元素，则返回 T 的零值和 false。
+// 它在遇到第一个满足条件的元素时便停止消费迭代器并将其关闭，因此可以用于存在满足条件元素的无穷迭代器。
+func (it Iter[T]) Find(pred func(T) bool) (T, bool) {
+	defer it.Close()
+	for x := range it {
+		if pred(x) {
+			return x, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// Position returns the zero-based index of the first element of the Iter that satisfies pred,
+// or (0, false) if there is none. Like Find, it stops consuming the Iter at the first match.
+//
+// Position 方法返回迭代器中第一个满足 pred 的元素的下标（从 0 开始）；若没有这样的元素，则返回 (0, false)。
+// 与 Find 一样，它在遇到第一个满足条件的元素时便停止消费迭代器。
+func (it Iter[T]) Position(pred func(T) bool) (int, bool) {
+	defer it.Close()
+	i := 0
+	for x := range it {
+		if pred(x) {
+			return i, true
+		}
+		i++
+	}
+	return 0, false
+}
+
+// First returns the first element of the Iter, or the zero value and false if it is empty. It consumes exactly one element.
+//
+// First 方法返回迭代器中的第一个元素；若迭代器为空，则返回 T 的零值和 false。它只消费一个元素。
+func (it Iter[T]) First() (T, bool) {
+	x, ok := <-it
+	return x, ok
+}
+
+// Last returns the last element of the Iter, or the zero value and false if it is empty, keeping only one element in memory.
+// DO NOT call this method on an infinite Iter, or it results in an infinite loop.
+//
+// Last 方法返回迭代器中的最后一个元素；若迭代器为空，则返回 T 的零值和 false。它只在内存中保留一个元素。
+// 不要在无穷迭代器上调用此方法，否则会导致死循环。
+func (it Iter[T]) Last() (T, bool) {
+	var last T
+	ok := false
+	for x := range it {
+		last, ok = x, true
+	}
+	return last, ok
+}
+
+// Nth returns the element of the Iter at the zero-based index n, or the zero value and false if the Iter has no more than n elements.
+// It consumes at most n+1 elements. Nth panics if n is negative.
+//
+// Nth 方法返回迭代器中下标为 n（从 0 开始）的元素；若迭代器中的元素不超过 n 个，则返回 T 的零值和 false。
+// 它最多消费 n+1 个元素。若 n 为负数，此方法会 panic。
+func (it Iter[T]) Nth(n int) (T, bool) {
+	if n < 0 {
+		panic("Nth: n must not be negative")
+	}
+	for i := 0; ; i++ {
+		x, ok := <-it
+		if !ok {
+			return x, false
+		}
+		if i == n {
+			return x, true
+		}
+	}
+}
+
+// ForEach calls fn with every element of the Iter, in order.
+// DO NOT call this method on an infinite Iter, or it results in an infinite loop.
+//
+// ForEach 方法按顺序对迭代器中的每个元素调用 fn。
+// 不要在无穷迭代器上调用此方法，否则会导致死循环。
+func (it Iter[T]) ForEach(fn func(T)) {
+	for x := range it {
+		fn(x)
+	}
+}
+
+// ForEachIndexed is like ForEach, but also passes the zero-based index of every element to fn.
+// DO NOT call this method on an infinite Iter, or it results in an infinite loop.
+//
+// ForEachIndexed 方法与 ForEach 相同，但同时将每个元素的下标（从 0 开始）传给 fn。
+// 不要在无穷迭代器上调用此方法，否则会导致死循环。
+func (it Iter[T]) ForEachIndexed(fn func(i int, x T)) {
+	i := 0
+	for x := range it {
+		fn(i, x)
+		i++
+	}
+}
+
+// ForEachParallel calls fn with every element of the Iter using the given number of worker goroutines,
+// and returns after the Iter ends and every call of fn has returned. The order of the calls is unspecified.
+// If workers <= 0, runtime.GOMAXPROCS(0) workers are used. If fn panics, the Iter is closed and the other workers stop
+// after their current call of fn, then the first panic is re-raised by ForEachParallel.
+// DO NOT call this method on an infinite Iter, or it results in an infinite loop.
+//
+// ForEachParallel 方法使用 workers 个 goroutine 对迭代器中的每个元素调用 fn，
+// 并在迭代器结束、且所有 fn 调用都已返回后才返回。调用的顺序是不确定的。
+// 若 workers <= 0，则使用 runtime.GOMAXPROCS(0) 个 goroutine。若 fn 发生 panic，迭代器会被关闭，
+// 其他 goroutine 在当前的 fn 调用返回后停止，随后 ForEachParallel 会重新抛出第一个 panic。
+// 不要在无穷迭代器上调用此方法，否则会导致死循环。
+func (it Iter[T]) ForEachParallel(workers int, fn func(T)) {
+	defer it.Close()
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	var wg sync.WaitGroup
+	var once sync.Once
+	var panicked interface{}
+	stop := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					once.Do(func() {
+						panicked = r
+						close(stop)
+						it.Close()
+					})
+				}
+			}()
+			for {
+				select {
+				case <-stop:
+					return
+				case x, ok := <-it:
+					if !ok {
+						return
+					}
+					fn(x)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if panicked != nil {
+		panic(panicked)
+	}
+}
+
+// ForEachParallelErr is like ForEachParallel, but returns the non-nil errors returned by fn, in unspecified order.
+//
+// ForEachParallelErr 方法与 ForEachParallel 相同，但会返回 fn 返回的所有非 nil 错误，错误的顺序是不确定的。
+func (it Iter[T]) ForEachParallelErr(workers int, fn func(T) error) []error {
+	var mu sync.Mutex
+	var errs []error
+	it.ForEachParallel(workers, func(x T) {
+		if err := fn(x); err != nil {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		}
+	})
+	return errs
+}
+
+// ParMap is like Map, but calls fn concurrently using the given number of worker goroutines,
+// while still sending the results in the order of the original elements.
+// If workers <= 0, runtime.GOMAXPROCS(0) workers are used. Use the ParMap function to project to another type.
+//
+// ParMap 方法与 Map 相同，但使用 workers 个 goroutine 并发地调用 fn，同时仍按原先元素的顺序发送结果。
+// 若 workers <= 0，则使用 runtime.GOMAXPROCS(0) 个 goroutine。若要映射为另一类型，请使用 ParMap 函数。
+func (it Iter[T]) ParMap(workers int, fn func(T) T) Iter[T] {
+	return ParMap(it, workers, fn)
+}
+
+// ParMap is like the Map function, but calls fn concurrently using the given number of worker goroutines,
+// while still sending the results in the order of the elements of it. At most about 2*workers elements are
+// in flight at a time, so a slow element holds the others back instead of letting the buffered results grow.
+// If workers <= 0, runtime.GOMAXPROCS(0) workers are used.
+//
+// ParMap 函数与 Map 函数相同，但使用 workers 个 goroutine 并发地调用 fn，同时仍按 it 中元素的顺序发送结果。
+// 同一时刻最多约有 2*workers 个元素在处理中，因此较慢的元素会阻塞其他元素，而不会使缓存的结果无限增长。
+// 若 workers <= 0，则使用 runtime.GOMAXPROCS(0) 个 goroutine。
+func ParMap[T, U any](it Iter[T], workers int, fn func(T) U) Iter[U] {
+	size, exact := it.SizeHint()
+	return parMap(it, it, size, exact, it.Unbounded(), workers, func(x T) (U, bool) { return fn(x), true })
+}
+
+// ParFilter is like Filter, but calls pred concurrently using the given number of worker goroutines,
+// while still sending the elements that satisfy it in their original order. Like ParMap,
+// at most about 2*workers elements are in flight at a time. If workers <= 0, runtime.GOMAXPROCS(0) workers are used.
+//
+// ParFilter 方法与 Filter 相同，但使用 workers 个 goroutine 并发地调用 pred，同时仍按原先的顺序发送满足条件的元素。
+// 与 ParMap 相同，同一时刻最多约有 2*workers 个元素在处理中。若 workers <= 0，则使用 runtime.GOMAXPROCS(0) 个 goroutine。
+func (it Iter[T]) ParFilter(workers int, pred func(T) bool) Iter[T] {
+	return parMap(it, it, -1, false, it.Unbounded(), workers, func(x T) (T, bool) { return x, pred(x) })
+}
+
+// parReduceChunk is the number of consecutive elements each worker of ParReduce reduces at a time.
+const parReduceChunk = 1024
+
+// ParReduce is like Reduce, but splits the Iter into chunks of consecutive elements, reduces them concurrently
+// using the given number of worker goroutines, each chunk starting from identity, and then combines the
+// partial results with fn in the order of the chunks. So fn must be associative, and identity must be
+// a true identity element of fn, i.e. fn(identity, x) == fn(x, identity) == x, but fn need not be commutative.
+// If workers <= 0, runtime.GOMAXPROCS(0) workers are used.
+// DO NOT call this method on an infinite Iter, or it results in an infinite loop.
+//
+// ParReduce 方法与 Reduce 相同，但会将迭代器拆分为由连续元素组成的块，使用 workers 个 goroutine 并发地
+// 从 identity 开始对每个块进行加总，然后按块的顺序使用 fn 合并各部分的结果。因此 fn 必须满足结合律，
+// 并且 identity 必须是 fn 的单位元，即 fn(identity, x) == fn(x, identity) == x，但 fn 不必满足交换律。
+// 若 workers <= 0，则使用 runtime.GOMAXPROCS(0) 个 goroutine。
+// 不要在无穷迭代器上调用此方法，否则会导致死循环。
+func (it Iter[T]) ParReduce(workers int, identity T, fn func(T, T) T) T {
+	mustBeBounded("ParReduce", it)
+	chunks := it.Batched(parReduceChunk)
+	partials := parMap(chunks, chunks, -1, false, false, workers, func(chunk []T) (T, bool) {
+		acc := identity
+		for _, x := range chunk {
+			acc = fn(acc, x)
+		}
+		return acc, true
+	})
+	return partials.Reduce(identity, fn)
+}
+
+// parMap calls fn concurrently on the elements received from in, which is closed by closing upstream,
+// and sends the results for which fn reports true in the order of the elements, as a stage of the given size hint
+// and boundedness.
+func parMap[T, U any](in <-chan T, upstream interface{ Close() }, size int, exact, unbounded bool, workers int, fn func(T) (U, bool)) Iter[U] {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	type result struct {
+		y  U
+		ok bool
+	}
+	type job struct {
+		x   T
+		res chan result
+	}
+	ch, s := newSizedStage[U](size, exact, upstream)
+	s.unbounded = unbounded
+	// every element gets its own result channel, queued in pending in the order of the elements
+	jobs := make(chan job)
+	pending := make(chan chan result, workers)
+	go func() {
+		defer close(jobs)
+		defer close(pending)
+		for x := range in {
+			res := make(chan result, 1)
+			select {
+			case pending <- res:
+			case <-s.done:
+				return
+			}
+			jobs <- job{x, res}
+		}
+	}()
+	for i := 0; i < workers; i++ {
+		go func() {
+			for j := range jobs {
+				y, ok := fn(j.x)
+				j.res <- result{y, ok}
+			}
+		}()
+	}
+	go func() {
+		defer s.finish()
+		for res := range pending {
+			select {
+			case r := <-res:
+				if r.ok && !send(s, ch, r.y) {
+					return
+				}
+			case <-s.done:
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// CollectInto appends the elements of the Iter to buf and returns the extended slice, like append does.
+// Reusing a buffer with enough capacity avoids allocating a new slice every time.
+// DO NOT call this method on an infinite Iter, or it results in an infinite loop.
+//
+// CollectInto 方法像 append 一样，将迭代器中的元素追加到 buf 中，并返回扩展后的 slice。
+// 重复使用一个容量足够的缓冲区，可以避免每次都分配新的 slice。
+// 不要在无穷迭代器上调用此方法，否则会导致死循环。
+func (it Iter[T]) CollectInto(buf []T) []T {
+	for x := range it {
+		buf = append(buf, x)
+	}
+	return buf
+}
+
+// CollectCap is like Collect, but pre-allocates a slice with capacity capHint.
+// DO NOT call this method on an infinite Iter, or it results in an infinite loop.
+//
+// CollectCap 方法与 Collect 相同，但会预先分配一个容量为 capHint 的 slice。
+// 不要在无穷迭代器上调用此方法，否则会导致死循环。
+func (it Iter[T]) CollectCap(capHint int) []T {
+	return it.CollectInto(make([]T, 0, capHint))
+}
+
+// CollectN collects at most n elements of the Iter into a slice pre-allocated with capacity n.
+// It receives no more than n elements, so it can be used on an infinite Iter. CollectN panics if n is negative.
+//
+// CollectN 方法将迭代器中最多 n 个元素收集到一个预先分配了容量 n 的 slice 中。
+// 它最多只接收 n 个元素，因此可以用于无穷迭代器。若 n 为负数，此方法会 panic。
+func (it Iter[T]) CollectN(n int) []T {
+	if n < 0 {
+		panic("CollectN: n must not be negative")
+	}
+	s := make([]T, 0, n)
+	for len(s) < n {
+		x, ok := <-it
+		if !ok {
+			break
+		}
+		s = append(s, x)
+	}
+	return s
+}
+
+// ErrTooManyElements is returned by CollectMax when the Iter has more elements than allowed.
+//
+// 当迭代器中的元素多于允许的数量时，CollectMax 方法返回 ErrTooManyElements。
+var ErrTooManyElements = errors.New("too many elements")
+
+// CollectMax is like Collect, but gives up once the Iter turns out to have more than max elements,
+// returning the first max elements and an error wrapping ErrTooManyElements. To detect this it receives
+// one element beyond max, which is lost to the caller, and then closes the Iter without consuming the rest of it.
+// This makes it safe to call on an Iter that might be infinite. CollectMax panics if max is negative.
+//
+// CollectMax 方法与 Collect 相同，但一旦发现迭代器中的元素多于 max 个便会放弃，
+// 返回前 max 个元素以及一个包装了 ErrTooManyElements 的错误。为此它会多接收一个元素（调用者无法再取得该元素），
+// 然后关闭迭代器而不消费其中剩余的元素。因此它可以安全地用于可能是无穷的迭代器。若 max 为负数，此方法会 panic。
+func (it Iter[T]) CollectMax(max int) ([]T, error) {
+	if max < 0 {
+		panic("CollectMax: max must not be negative")
+	}
+	defer it.Close()
+	var s []T
+	for x := range it {
+		if len(s) == max {
+			return s, fmt.Errorf("CollectMax: more than %d elements: %w", max, ErrTooManyElements)
+		}
+		s = append(s, x)
+	}
+	return s, nil
+}
+
+// Frequencies returns how many times each element occurs in the Iter. An empty Iter gives an empty, non-nil map.
+// DO NOT call this function on an infinite Iter, or it results in an infinite loop.
+//
+// Frequencies 函数返回迭代器中每个元素出现的次数。空迭代器返回一个空的、非 nil 的 map。
+// 不要在无穷迭代器上调用此函数，否则会导致死循环。
+func Frequencies[T comparable](it Iter[T]) map[T]int {
+	return CountBy(it, func(x T) T { return x })
+}
+
+// CountBy returns how many elements of the Iter fall under each key.
+// An empty Iter gives an empty, non-nil map.
+// DO NOT call this function on an infinite Iter, or it results in an infinite loop.
+//
+// CountBy 函数返回迭代器中对应每个 key 的元素的个数。空迭代器返回一个空的、非 nil 的 map。
+// 不要在无穷迭代器上调用此函数，否则会导致死循环。
+func CountBy[T any, K comparable](it Iter[T], key func(T) K) map[K]int {
+	counts := make(map[K]int)
+	for x := range it {
+		counts[key(x)]++
+	}
+	return counts
+}
+
+// JoinString formats the elements of the Iter in their default format, as fmt.Sprint does,
+// and joins them with sep, in a single pass. Integers are formatted in base 10.
+// DO NOT call this method on an infinite Iter, or it results in an infinite loop.
+//
+// JoinString 方法像 fmt.Sprint 一样以默认格式格式化迭代器中的元素，并用 sep 连接起来，只需遍历一次。整数会被格式化为十进制。
+// 不要在无穷迭代器上调用此方法，否则会导致死循环。
+func (it Iter[T]) JoinString(sep string) string {
+	var b strings.Builder
+	var buf []byte
+	first := true
+	for x := range it {
+		if !first {
+			b.WriteString(sep)
+		}
+		buf = appendElement(buf[:0], x)
+		b.Write(buf)
+		first = false
+	}
+	return b.String()
+}
+
+// appendElement appends x to buf in its default format, as fmt.Append does, but without allocating
+// for integers, floats, strings and bools.
+func appendElement[T any](buf []byte, x T) []byte {
+	switch v := any(x).(type) {
+	case int:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int8:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int16:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int32:
+		return strconv.AppendInt(buf, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(buf, v, 10)
+	case uint:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint8:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint16:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint32:
+		return strconv.AppendUint(buf, uint64(v), 10)
+	case uint64:
+		return strconv.AppendUint(buf, v, 10)
+	case float32:
+		return strconv.AppendFloat(buf, float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.AppendFloat(buf, v, 'g', -1, 64)
+	case string:
+		return append(buf, v...)
+	case bool:
+		return strconv.AppendBool(buf, v)
+	default:
+		return fmt.Append(buf, x)
+	}
+}
+
+// JoinStringFunc is like JoinString, but formats every element with format.
+// DO NOT call this method on an infinite Iter, or it results in an infinite loop.
+//
+// JoinStringFunc 方法与 JoinString 相同，但使用 format 来格式化每个元素。
+// 不要在无穷迭代器上调用此方法，否则会导致死循环。
+func (it Iter[T]) JoinStringFunc(sep string, format func(T) string) string {
+	var b strings.Builder
+	first := true
+	for x := range it {
+		if !first {
+			b.WriteString(sep)
+		}
+		b.WriteString(format(x))
+		first = false
+	}
+	return b.String()
+}
+
+// WriteTo writes the elements of the Iter to w in their default format, as fmt.Print does, one per line,
+// and returns the number of bytes written. Integers are written in base 10. It implements io.WriterTo.
+// Writing is buffered, and it stops at the first write error, closing the Iter without consuming the rest of it.
+// DO NOT call this method on an infinite Iter, or it results in an infinite loop.
+//
+// WriteTo 方法像 fmt.Print 一样以默认格式将迭代器中的元素写入 w，每行一个，并返回写入的字节数。
+// 整数会以十进制写入。它实现了 io.WriterTo 接口。
+// 写入是带缓冲的，并在遇到第一个写入错误时停止，关闭迭代器而不消费其中剩余的元素。
+// 不要在无穷迭代器上调用此方法，否则会导致死循环。
+func (it Iter[T]) WriteTo(w io.Writer) (n int64, err error) {
+	return it.WriteToSep(w, "\n")
+}
+
+// WriteToSep is like WriteTo, but writes sep after every element instead of a newline.
+//
+// WriteToSep 方法与 WriteTo 相同，但在每个元素之后写入 sep，而不是换行符。
+func (it Iter[T]) WriteToSep(w io.Writer, sep string) (n int64, err error) {
+	defer it.Close()
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	var buf []byte
+	for x := range it {
+		buf = appendElement(buf[:0], x)
+		buf = append(buf, sep...)
+		if _, err := bw.Write(buf); err != nil {
+			return cw.n, err
+		}
+	}
+	err = bw.Flush()
+	return cw.n, err
+}
+
+// countingWriter counts the bytes successfully written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// WriteBinary writes the elements of the Iter to w as signed varints (see binary.PutVarint),
+// and returns the number of bytes written. It is the inverse of FromBinary.
+// Writing is buffered, and it stops at the first write error, closing the Iter without consuming the rest of it.
+// DO NOT call this function on an infinite Iter, or it results in an infinite loop.
+//
+// WriteBinary 函数将迭代器中的元素以有符号 varint（参见 binary.PutVarint）的形式写入 w，
+// 并返回写入的字节数。它是 FromBinary 的逆操作。
+// 写入是带缓冲的，并在遇到第一个写入错误时停止，关闭迭代器而不消费其中剩余的元素。
+// 不要在无穷迭代器上调用此函数，否则会导致死循环。
+func WriteBinary(it Iter[int], w io.Writer) (n int64, err error) {
+	defer it.Close()
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	buf := make([]byte, binary.MaxVarintLen64)
+	for x := range it {
+		k := binary.PutVarint(buf, int64(x))
+		if _, err := bw.Write(buf[:k]); err != nil {
+			return cw.n, err
+		}
+	}
+	err = bw.Flush()
+	return cw.n, err
+}
+
+// EncodeJSON writes the elements of the Iter to w as a JSON array, e.g. [1,2,3], without holding them in memory.
+// Every element is encoded with json.Marshal. Writing is buffered, and it stops at the first encoding or write error,
+// closing the Iter without consuming the rest of it.
+// DO NOT call this method on an infinite Iter, or it results in an infinite loop.
+//
+// EncodeJSON 方法将迭代器中的元素以 JSON 数组的形式写入 w，例如 [1,2,3]，无需将元素全部保存在内存中。
+// 每个元素都使用 json.Marshal 编码。写入是带缓冲的，并在遇到第一个编码或写入错误时停止，关闭迭代器而不消费其中剩余的元素。
+// 不要在无穷迭代器上调用此方法，否则会导致死循环。
+func (it Iter[T]) EncodeJSON(w io.Writer) error {
+	return it.EncodeJSONIndent(w, "", "")
+}
+
+// EncodeJSONIndent is like EncodeJSON, but indents the array like json.MarshalIndent does:
+// every element is on its own line, beginning with prefix followed by indent, and is itself indented
+// with json.MarshalIndent. An empty indent gives the compact form written by EncodeJSON.
+//
+// EncodeJSONIndent 方法与 EncodeJSON 相同，但会像 json.MarshalIndent 一样对数组进行缩进：
+// 每个元素各占一行，以 prefix 加上 indent 开头，其自身也使用 json.MarshalIndent 进行缩进。
+// indent 为空时，与 EncodeJSON 的紧凑格式相同。
+func (it Iter[T]) EncodeJSONIndent(w io.Writer, prefix, indent string) error {
+	defer it.Close()
+	bw := bufio.NewWriter(w)
+	var buf []byte
+	first := true
+	bw.WriteByte('[')
+	for x := range it {
+		buf = buf[:0]
+		if !first {
+			buf = append(buf, ',')
+		}
+		if indent != "" {
+			buf = append(buf, '\n')
+			buf = append(buf, prefix...)
+			buf = append(buf, indent...)
+		}
+		var elem []byte
+		var err error
+		if indent != "" {
+			elem, err = json.MarshalIndent(x, prefix+indent, indent)
+		} else {
+			elem, err = json.Marshal(x)
+		}
+		if err != nil {
+			return err
+		}
+		buf = append(buf, elem...)
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+		first = false
+	}
+	if indent != "" && !first {
+		bw.WriteByte('\n')
+		bw.WriteString(prefix)
+	}
+	bw.WriteByte(']')
+	return bw.Flush()
+}
+
+// Contains reports whether x is an element of the Iter. It stops consuming the Iter as soon as x is found,
+// so it can be used on an infinite Iter that contains x.
+//
+// Contains 函数判断 x 是否为迭代器中的元素。一旦找到 x 便停止消费迭代器，因此可以用于包含 x 的无穷迭代器。
+func Contains[T comparable](it Iter[T], x T) bool {
+	return it.Any(func(y T) bool { return y == x })
+}
+
+// IsSorted reports whether the elements of the Iter are in non-decreasing order.
+// It returns false at the first inversion, and closes the Iter without consuming the rest of it.
+// An empty or single-element Iter is sorted.
+//
+// IsSorted 函数判断迭代器中的元素是否按非递减顺序排列。
+// 它在遇到第一个逆序时立即返回 false，关闭迭代器而不消费其中剩余的元素。空的或只有一个元素的迭代器是有序的。
+func IsSorted[T cmp.Ordered](it Iter[T]) bool {
+	return it.IsSortedBy(func(a, b T) bool { return a < b })
+}
+
+// IsSortedBy is like IsSorted, but uses less to compare the elements:
+// the Iter is sorted if no element is less than the one before it.
+//
+// IsSortedBy 方法与 IsSorted 相同，但使用 less 来比较元素：若没有元素小于其前一个元素，则迭代器是有序的。
+func (it Iter[T]) IsSortedBy(less func(a, b T) bool) bool {
+	defer it.Close()
+	prev, ok := <-it
+	if !ok {
+		return true
+	}
+	for x := range it {
+		if less(x, prev) {
+			return false
+		}
+		prev = x
+	}
+	return true
+}
+
+// SortedWithin sorts an Iter whose elements are each at most k positions away from their place in sorted order,
+// keeping only k+1 elements at a time, so it also works on an infinite Iter. See SortedWithinBy.
+//
+// SortedWithin 函数对每个元素距其排序后的位置都不超过 k 个位置的迭代器进行排序，同一时刻只保存 k+1 个元素，
+// 因此也可以用于无穷迭代器。请参见 SortedWithinBy。
+func SortedWithin[T cmp.Ordered](it Iter[T], k int) Iter[T] {
+	return it.SortedWithinBy(k, func(a, b T) bool { return a < b }, nil)
+}
+
+// SortedWithinBy creates a new Iter of the elements of the Iter sorted by less, provided that every element is
+// at most k positions away from its place in sorted order, as with timestamps merged from slightly skewed sources.
+// It keeps the next k+1 elements in a min-heap and sends the least of them, which under that bound must come next,
+// so it uses O(k) memory instead of buffering the whole Iter. The result is only sorted if the bound holds:
+// an element that arrives too late is sent as soon as possible, after a greater one, and onViolation, unless nil,
+// is called with the two first. k = 0 sends the elements as they are. The sort is not stable.
+// SortedWithinBy panics if k is negative.
+//
+// SortedWithinBy 方法生成一个新的迭代器，按 less 对原迭代器的元素排序，前提是每个元素距其排序后的位置都不超过 k 个位置，
+// 例如从时钟略有偏差的多个来源合并而来的时间戳。它将接下来的 k+1 个元素保存在一个最小堆中，并发送其中最小的元素，
+// 在上述前提下它必然是下一个元素，因此只使用 O(k) 的内存，而不必缓存整个迭代器。只有在前提成立时结果才是有序的：
+// 到达得太晚的元素会被尽快发送，排在一个更大的元素之后；若 onViolation 不为 nil，会先以这两个元素调用它。
+// k = 0 时元素按原样发送。该排序不是稳定的。若 k 为负数，此方法会 panic。
+func (it Iter[T]) SortedWithinBy(k int, less func(a, b T) bool, onViolation func(prev, x T)) Iter[T] {
+	if k < 0 {
+		panic("SortedWithinBy: k must not be negative")
+	}
+	size, exact := it.SizeHint()
+	ch, s := newSizedStage[T](size, exact, it)
+	s.unbounded = it.Unbounded()
+	go func() {
+		defer s.finish()
+		// the heap grows on demand beyond the capacity, as k may be far more than the elements of the Iter
+		h := &sortHeap[T]{s: make([]T, 0, collectCap[T](min(k, math.MaxInt-1)+1)), less: less}
+		var prev T
+		sent := false
+		pop := func() bool {
+			x := heap.Pop(h).(T)
+			if sent && onViolation != nil && less(x, prev) {
+				onViolation(prev, x)
+			}
+			prev, sent = x, true
+			return send(s, ch, x)
+		}
+		for x := range it {
+			heap.Push(h, x)
+			if h.Len() > k && !pop() {
+				return
+			}
+		}
+		for h.Len() > 0 {
+			if !pop() {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// sortHeap is a min-heap of elements ordered by less, implementing heap.Interface for SortedWithinBy.
+type sortHeap[T any] struct {
+	s    []T
+	less func(a, b T) bool
+}
+
+func (h *sortHeap[T]) Len() int           { return len(h.s) }
+func (h *sortHeap[T]) Less(i, j int) bool { return h.less(h.s[i], h.s[j]) }
+func (h *sortHeap[T]) Swap(i, j int)      { h.s[i], h.s[j] = h.s[j], h.s[i] }
+func (h *sortHeap[T]) Push(x any)         { h.s = append(h.s, x.(T)) }
+
+func (h *sortHeap[T]) Pop() any {
+	x := h.s[len(h.s)-1]
+	h.s = h.s[:len(h.s)-1]
+	return x
+}
+
+// Equal reports whether the Iter and other contain the same elements in the same order.
+// Both are consumed in lockstep, and it returns false at the first mismatch or as soon as one of them ends early.
+//
+// Equal 函数判断迭代器与 other 是否按相同的顺序包含相同的元素。
+// 两者会被同步消费，并在遇到第一个不同的元素，或其中一个提前结束时立即返回 false。
+func Equal[T comparable](it, other Iter[T]) bool {
+	defer it.Close()
+	defer other.Close()
+	for {
+		x, ok1 := <-it
+		y, ok2 := <-other
+		if ok1 != ok2 || x != y {
+			return false
+		}
+		if !ok1 {
+			return true
+		}
+	}
+}
+
+// Compare compares the Iter and other lexicographically, returning -1, 0 or +1.
+// The first differing element decides the result, and a proper prefix is less than the longer Iter.
+// Both are consumed in lockstep, and it returns as soon as the result is known.
+//
+// Compare 函数按字典序比较迭代器与 other，返回 -1、0 或 +1。
+// 第一个不同的元素决定比较结果，并且真前缀小于较长的迭代器。两者会被同步消费，一旦结果确定便立即返回。
+func Compare[T cmp.Ordered](it, other Iter[T]) int {
+	defer it.Close()
+	defer other.Close()
+	for {
+		x, ok1 := <-it
+		y, ok2 := <-other
+		switch {
+		case !ok1 && !ok2:
+			return 0
+		case !ok1:
+			return -1
+		case !ok2:
+			return +1
+		case x < y:
+			return -1
+		case x > y:
+			return +1
+		}
+	}
+}
+
+// GroupByToMap puts every element of the Iter into the slice for its key, keeping the order of the elements within every slice.
+// An empty Iter gives an empty, non-nil map.
+// DO NOT call this function on an infinite Iter, or it results in an infinite loop.
+//
+// GroupByToMap 函数将迭代器中的每个元素放入其 key 对应的 slice 中，每个 slice 中的元素保持原有顺序。
+// 空迭代器返回一个空的、非 nil 的 map。
+// 不要在无穷迭代器上调用此函数，否则会导致死循环。
+func GroupByToMap[T any, K comparable](it Iter[T], key func(T) K) map[K][]T {
+	groups := make(map[K][]T)
+	for x := range it {
+		k := key(x)
+		groups[k] = append(groups[k], x)
+	}
+	return groups
+}
+
+// Drain receives and discards every element of the Iter until it ends.
+// It is a way to let the goroutines behind a partially consumed, finite Iter finish, though Close does so without
+// receiving the rest. DO NOT call this method on an infinite Iter, or it results in an infinite loop; use Close instead.
+//
+// Drain 方法接收并丢弃迭代器中的所有元素，直到迭代器结束。
+// 对于只消费了一部分的有限迭代器，可以用它来让其背后的 goroutine 结束运行，不过 Close 无需接收剩余元素即可做到这一点。
+// 不要在无穷迭代器上调用此方法，否则会导致死循环；请使用 Close。
+func (it Iter[T]) Drain() {
+	for range it {
+	}
+}
+
+// DrainN discards at most n elements of the Iter and returns how many were discarded.
+// It is safe to call on an infinite Iter. DrainN panics if n is negative.
+//
+// DrainN 方法最多丢弃迭代器中的 n 个元素，并返回实际丢弃的个数。它可以安全地用于无穷迭代器。
+// 若 n 为负数，此方法会 panic。
+func (it Iter[T]) DrainN(n int) int {
+	if n < 0 {
+		panic("DrainN: n must not be negative")
+	}
+	count := 0
+	for count < n {
+		if _, ok := <-it; !ok {
+			break
+		}
+		count++
+	}
+	return count
+}
+
+// Median returns the median of the elements of the Iter, which is the average of the two middle elements
+// if there is an even number of them, or (0, false) if the Iter is empty.
+// The elements are collected and a selection algorithm is used instead of sorting them.
+// DO NOT call this function on an infinite Iter, or it results in an infinite loop.
+//
+// Median 函数返回迭代器中元素的中位数；若元素个数为偶数，则为中间两个元素的平均值；若迭代器为空，则返回 (0, false)。
+// 此函数会收集所有元素，并使用选择算法而不是对元素进行排序。
+// 不要在无穷迭代器上调用此函数，否则会导致死循环。
+func Median[T Number](it Iter[T]) (float64, bool) {
+	s := it.Collect()
+	n := len(s)
+	if n == 0 {
+		return 0, false
+	}
+	if n%2 == 1 {
+		return float64(selectKth(s, n/2)), true
+	}
+	lower := selectKth(s, n/2-1)
+	// after the selection, the elements after index n/2-1 are the larger half
+	upper := s[n/2]
+	for _, x := range s[n/2+1:] {
+		if x < upper {
+			upper = x
+		}
+	}
+	return float64(lower)/2 + float64(upper)/2, true
+}
+
+// Quantile returns the q-quantile of the elements of the Iter using the nearest-rank method,
+// i.e. the smallest element that is greater than or equal to a fraction q of the elements,
+// or the zero value and false if the Iter is empty. Quantile(0) is the minimum and Quantile(1) is the maximum.
+// The elements are collected and a selection algorithm is used instead of sorting them.
+// Quantile panics if q is not in [0, 1].
+// DO NOT call this function on an infinite Iter, or it results in an infinite loop.
+//
+// Quantile 函数使用最近秩方法返回迭代器中元素的 q 分位数，即不小于其中 q 比例元素的最小元素；
+// 若迭代器为空，则返回 T 的零值和 false。Quantile(0) 为最小值，Quantile(1) 为最大值。
+// 此函数会收集所有元素，并使用选择算法而不是对元素进行排序。若 q 不在 [0, 1] 区间中，此函数会 panic。
+// 不要在无穷迭代器上调用此函数，否则会导致死循环。
+func Quantile[T cmp.Ordered](it Iter[T], q float64) (T, bool) {
+	if !(q >= 0 && q <= 1) {
+		panic(fmt.Sprintf("Quantile: q = %v is not in [0, 1]", q))
+	}
+	s := it.Collect()
+	if len(s) == 0 {
+		var zero T
+		return zero, false
+	}
+	// q*len(s) carries the rounding error of q, e.g. 0.07*100 is 7.000000000000001, so a product
+	// that is an integer up to that error is taken as the integer itself
+	x := q * float64(len(s))
+	if r := math.Round(x); math.Abs(x-r) <= 1e-9*x {
+		x = r
+	}
+	rank := int(math.Ceil(x))
+	if rank < 1 {
+		rank = 1
+	}
+	return selectKth(s, rank-1), true
+}
+
+// selectKth returns the element that would be at index k if s were sorted, reordering s with quickselect
+// so that the elements before index k are no larger and those after it are no smaller.
+func selectKth[T cmp.Ordered](s []T, k int) T {
+	lo, hi := 0, len(s)-1
+	for lo < hi {
+		pivot := s[lo+(hi-lo)/2]
+		// three-way partition: s[lo:lt] < pivot, s[lt:gt+1] == pivot, s[gt+1:hi+1] > pivot
+		lt, i, gt := lo, lo, hi
+		for i <= gt {
+			switch {
+			case s[i] < pivot:
+				s[lt], s[i] = s[i], s[lt]
+				lt++
+				i++
+			case s[i] > pivot:
+				s[i], s[gt] = s[gt], s[i]
+				gt--
+			default:
+				i++
+			}
+		}
+		switch {
+		case k < lt:
+			hi = lt - 1
+		case k > gt:
+			lo = gt + 1
+		default:
+			return pivot
+		}
+	}
+	return s[k]
+}
+
+// QuantileSketch estimates a single quantile of a stream of integers in constant memory
+// using the P² algorithm of Jain and Chlamtac, which keeps only five markers whose heights are
+// adjusted with piecewise-parabolic interpolation as observations arrive.
+// The estimate is exact (nearest rank) for up to five observations. Beyond that it is an approximation,
+// which is usually within a few percent on smooth distributions, but can be poor on heavily skewed or
+// multimodal data, on data with few distinct values, and for extreme quantiles on short streams.
+// The estimate depends on the order of the observations, but is deterministic for a fixed sequence.
+// A QuantileSketch is not safe for concurrent use.
+//
+// QuantileSketch 类型使用 Jain 和 Chlamtac 提出的 P² 算法，以常数内存估计整数流的某个分位数。
+// 它只保存五个标记，并在每次观测时使用分段抛物线插值调整标记的高度。
+// 观测数不超过五个时，估计值是精确的（最近秩方法）；超过五个时为近似值，在平滑分布上误差通常在几个百分点以内，
+// 但在严重偏斜或多峰的数据、取值种类很少的数据，以及短数据流的极端分位数上，误差可能较大。
+// 估计值依赖于观测的顺序，但对于固定的观测序列，结果是确定的。QuantileSketch 不是并发安全的。
+type QuantileSketch struct {
+	p       float64
+	count   int
+	heights [5]float64
+	pos     [5]float64
+	desired [5]float64
+	incr    [5]float64
+}
+
+// NewQuantileSketch creates a QuantileSketch estimating the q-quantile. It panics if q is not in [0, 1].
+//
+// NewQuantileSketch 函数创建一个估计 q 分位数的 QuantileSketch。若 q 不在 [0, 1] 区间中，此函数会 panic。
+func NewQuantileSketch(q float64) *QuantileSketch {
+	if !(q >= 0 && q <= 1) {
+		panic(fmt.Sprintf("NewQuantileSketch: q = %v is not in [0, 1]", q))
+	}
+	return &QuantileSketch{
+		p:       q,
+		pos:     [5]float64{1, 2, 3, 4, 5},
+		desired: [5]float64{1, 1 + 2*q, 1 + 4*q, 3 + 2*q, 5},
+		incr:    [5]float64{0, q / 2, q, (1 + q) / 2, 1},
+	}
+}
+
+// Observe adds x to the observations of the QuantileSketch.
+//
+// Observe 方法将 x 加入 QuantileSketch 的观测值中。
+func (s *QuantileSketch) Observe(x int) {
+	v := float64(x)
+	if s.count < 5 {
+		// keep the first five observations sorted, they become the initial marker heights
+		i := s.count
+		for i > 0 && s.heights[i-1] > v {
+			s.heights[i] = s.heights[i-1]
+			i--
+		}
+		s.heights[i] = v
+		s.count++
+		return
+	}
+	s.count++
+
+	var k int
+	switch {
+	case v < s.heights[0]:
+		s.heights[0] = v
+		k = 0
+	case v >= s.heights[4]:
+		s.heights[4] = v
+		k = 3
+	default:
+		for k = 0; k < 3 && v >= s.heights[k+1]; k++ {
+		}
+	}
+	for i := k + 1; i < 5; i++ {
+		s.pos[i]++
+	}
+	for i := range s.desired {
+		s.desired[i] += s.incr[i]
+	}
+
+	for i := 1; i < 4; i++ {
+		d := s.desired[i] - s.pos[i]
+		if (d >= 1 && s.pos[i+1]-s.pos[i] > 1) || (d <= -1 && s.pos[i-1]-s.pos[i] < -1) {
+			sign := 1
+			if d < 0 {
+				sign = -1
+			}
+			h := s.parabolic(i, float64(sign))
+			if !(s.heights[i-1] < h && h < s.heights[i+1]) {
+				h = s.linear(i, sign)
+			}
+			s.heights[i] = h
+			s.pos[i] += float64(sign)
+		}
+	}
+}
+
+func (s *QuantileSketch) parabolic(i int, d float64) float64 {
+	h, n := &s.heights, &s.pos
+	return h[i] + d/(n[i+1]-n[i-1])*
+		((n[i]-n[i-1]+d)*(h[i+1]-h[i])/(n[i+1]-n[i])+
+			(n[i+1]-n[i]-d)*(h[i]-h[i-1])/(n[i]-n[i-1]))
+}
+
+func (s *QuantileSketch) linear(i, d int) float64 {
+	return s.heights[i] + float64(d)*(s.heights[i+d]-s.heights[i])/(s.pos[i+d]-s.pos[i])
+}
+
+// Value returns the current estimate of the quantile, or 0 if nothing has been observed.
+//
+// Value 方法返回当前的分位数估计值；若尚无观测值，则返回 0。
+func (s *QuantileSketch) Value() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	if s.count <= 5 {
+		rank := int(math.Ceil(s.p * float64(s.count)))
+		if rank < 1 {
+			rank = 1
+		}
+		return s.heights[rank-1]
+	}
+	if s.p == 0 {
+		return s.heights[0]
+	}
+	if s.p == 1 {
+		return s.heights[4]
+	}
+	return s.heights[2]
+}
+
+// Count returns the number of observations of the QuantileSketch.
+//
+// Count 方法返回 QuantileSketch 的观测值个数。
+func (s *QuantileSketch) Count() int {
+	return s.count
+}
+
+// QuantileEst estimates the q-quantile of the elements of the Iter in constant memory using a QuantileSketch,
+// whose documentation describes the accuracy of the estimate. It returns 0 for an empty Iter and panics if q is not in [0, 1].
+// To estimate a quantile of an infinite Iter, feed a QuantileSketch as the elements pass by instead.
+// DO NOT call this function on an infinite Iter, or it results in an infinite loop.
+//
+// QuantileEst 函数使用 QuantileSketch 以常数内存估计迭代器中元素的 q 分位数，估计的精度参见 QuantileSketch 的文档。
+// 空迭代器返回 0；若 q 不在 [0, 1] 区间中，此函数会 panic。若要估计无穷迭代器的分位数，可在元素流经时将其交给 QuantileSketch。
+// 不要在无穷迭代器上调用此函数，否则会导致死循环。
+func QuantileEst(it Iter[int], q float64) float64 {
+	s := NewQuantileSketch(q)
+	for x := range it {
+		s.Observe(x)
+	}
+	return s.Value()
+}
+
+// Histogram counts the elements of the Iter in buckets of width bucketWidth, returning a map from the lower bound
+// of each non-empty bucket to its count. The bucket of x is [k*bucketWidth, (k+1)*bucketWidth) where k is x divided
+// by bucketWidth rounded towards negative infinity, so that -1 falls in the bucket starting at -bucketWidth.
+// The lowest bucket, whose lower bound may be less than math.MinInt, is keyed by math.MinInt instead.
+// Histogram panics if bucketWidth is not positive.
+// DO NOT call this function on an infinite Iter, or it results in an infinite loop.
+//
+// Histogram 函数将迭代器中的元素按宽度为 bucketWidth 的桶进行计数，返回每个非空桶的下界到其计数的映射。
+// x 所在的桶为 [k*bucketWidth, (k+1)*bucketWidth)，其中 k 为 x 除以 bucketWidth 后向负无穷取整的结果，
+// 因此 -1 落在以 -bucketWidth 为下界的桶中。最低的桶的下界可能小于 math.MinInt，此时以 math.MinInt 作为其键。
+// 若 bucketWidth 不是正数，此函数会 panic。
+// 不要在无穷迭代器上调用此函数，否则会导致死循环。
+func Histogram(it Iter[int], bucketWidth int) map[int]int {
+	if bucketWidth <= 0 {
+		panic(fmt.Sprintf("Histogram: bucketWidth = %d is not positive", bucketWidth))
+	}
+	buckets := make(map[int]int)
+	for x := range it {
+		r := floorMod(x, bucketWidth)
+		// x-r would wrap around below math.MinInt
+		if x < math.MinInt+r {
+			buckets[math.MinInt]++
+			continue
+		}
+		buckets[x-r]++
+	}
+	return buckets
+}
+
+// floorMod returns x modulo m with the sign of m, unlike the % operator which truncates towards zero.
+func floorMod(x, m int) int {
+	r := x % m
+	if r != 0 && (r < 0) != (m < 0) {
+		r += m
+	}
+	return r
+}
+
+// HistogramBounds counts the elements of the Iter in the intervals delimited by bounds, which must be strictly increasing.
+// The result has len(bounds)+1 counts: the first counts the elements less than bounds[0], the i-th counts those in
+// [bounds[i-1], bounds[i]), and the last counts those greater than or equal to bounds[len(bounds)-1].
+// HistogramBounds panics if bounds is not strictly increasing.
+// DO NOT call this function on an infinite Iter, or it results in an infinite loop.
+//
+// HistogramBounds 函数按 bounds 划分的区间对迭代器中的元素进行计数，bounds 必须严格递增。
+// 结果包含 len(bounds)+1 个计数：第一个为小于 bounds[0] 的元素个数，第 i 个为落在 [bounds[i-1], bounds[i]) 中的元素个数，
+// 最后一个为大于等于 bounds[len(bounds)-1] 的元素个数。若 bounds 不是严格递增的，此函数会 panic。
+// 不要在无穷迭代器上调用此函数，否则会导致死循环。
+func HistogramBounds(it Iter[int], bounds []int) []int {
+	for i := 1; i < len(bounds); i++ {
+		if bounds[i] <= bounds[i-1] {
+			panic(fmt.Sprintf("HistogramBounds: bounds %v are not strictly increasing", bounds))
+		}
+	}
+	counts := make([]int, len(bounds)+1)
+	for x := range it {
+		counts[sort.Search(len(bounds), func(i int) bool { return bounds[i] > x })]++
+	}
+	return counts
+}
+
+// Mode returns the most frequent element of the Iter and its count, or two zero values and false if the Iter is empty.
+// If several elements share the highest count, the one that occurs first in the Iter is returned.
+// DO NOT call this function on an infinite Iter, or it results in an infinite loop.
+//
+// Mode 函数返回迭代器中出现次数最多的元素及其出现次数；若迭代器为空，则返回两个零值和 false。
+// 若有多个元素的出现次数相同且最多，则返回在迭代器中最先出现的那个。
+// 不要在无穷迭代器上调用此函数，否则会导致死循环。
+func Mode[T comparable](it Iter[T]) (T, int, bool) {
+	modes, count := modesOf(it)
+	if len(modes) == 0 {
+		var zero T
+		return zero, 0, false
+	}
+	return modes[0], count, true
+}
+
+// Modes returns all the elements of the Iter that share the highest count, in the order of their first occurrence,
+// or nil if the Iter is empty.
+// DO NOT call this function on an infinite Iter, or it results in an infinite loop.
+//
+// Modes 函数返回迭代器中所有出现次数最多的元素，按它们首次出现的顺序排列；若迭代器为空，则返回 nil。
+// 不要在无穷迭代器上调用此函数，否则会导致死循环。
+func Modes[T comparable](it Iter[T]) []T {
+	modes, _ := modesOf(it)
+	return modes
+}
+
+func modesOf[T comparable](it Iter[T]) ([]T, int) {
+	counts := make(map[T]int)
+	var order []T
+	for x := range it {
+		if counts[x] == 0 {
+			order = append(order, x)
+		}
+		counts[x]++
+	}
+	var modes []T
+	max := 0
+	for _, x := range order {
+		switch c := counts[x]; {
+		case c > max:
+			max = c
+			modes = append(modes[:0], x)
+		case c == max:
+			modes = append(modes, x)
+		}
+	}
+	return modes, max
+}
+
+// Hash returns an order-sensitive 64-bit FNV-1a digest of the elements of the Iter, each fed to the hash
+// as 8 bytes in big-endian order. Equal sequences always have the same digest, across runs and platforms,
+// while different sequences, including reorderings of the same elements, almost certainly do not.
+// The digest of an empty Iter is the FNV-1a offset basis 14695981039346656037.
+// hash/maphash is deliberately not used, since its seed is random per process and its digests cannot be
+// compared across runs. The digest is not cryptographically secure.
+// DO NOT call this function on an infinite Iter, or it results in an infinite loop.
+//
+// Hash 函数返回迭代器中元素的 64 位 FNV-1a 摘要，摘要与元素顺序相关，每个元素以 8 字节大端序输入哈希。
+// 相同的序列在不同的运行和平台上总有相同的摘要，而不同的序列（包括相同元素的不同排列）几乎一定有不同的摘要。
+// 空迭代器的摘要为 FNV-1a 的初始偏移量 14695981039346656037。
+// 此函数有意不使用 hash/maphash，因为它的种子在每个进程中随机生成，摘要无法在不同的运行之间比较。此摘要不具备密码学安全性。
+// 不要在无穷迭代器上调用此函数，否则会导致死循环。
+func Hash(it Iter[int]) uint64 {
+	return HashWith(it, fnv.New64a())
+}
+
+// HashWith writes the elements of the Iter to h, each as 8 bytes in big-endian order, and returns h.Sum64().
+// h is not reset first, so the elements are appended to anything already written to it.
+// DO NOT call this function on an infinite Iter, or it results in an infinite loop.
+//
+// HashWith 函数将迭代器中的元素以 8 字节大端序写入 h，并返回 h.Sum64()。
+// 此函数不会先重置 h，因此元素会追加在 h 中已写入的数据之后。
+// 不要在无穷迭代器上调用此函数，否则会导致死循环。
+func HashWith(it Iter[int], h hash.Hash64) uint64 {
+	var buf [8]byte
+	for x := range it {
+		binary.BigEndian.PutUint64(buf[:], uint64(x))
+		h.Write(buf[:])
+	}
+	return h.Sum64()
+}
+
+// Pair holds two values, such as the corresponding elements of two zipped Iters.
+//
+// Pair 类型保存两个值，例如两个迭代器被 Zip 后相对应的元素。
+type Pair[T, U any] struct {
+	First  T
+	Second U
+}
+
+// PairIter is an iterator of Pairs, it can be consumed with a for range loop just like an Iter.
+//
+// PairIter 类型是 Pair 的迭代器，与 Iter 一样可以使用 for range 循环遍历。
+type PairIter[T, U any] <-chan Pair[T, U]
+
+// Zip creates a PairIter of the corresponding elements of a and b, which ends as soon as either of them ends.
+//
+// Zip 函数创建一个由 a 和 b 中对应元素组成的 PairIter，当其中任一迭代器结束时，PairIter 即结束。
+func Zip[T, U any](a Iter[T], b Iter[U]) PairIter[T, U] {
+	ch := make(chan Pair[T, U])
+	s := register(PairIter[T, U](ch), func() { close(ch) }, -1, false, a, b)
+	go func() {
+		defer s.finish()
+		for x := range a {
+			y, ok := <-b
+			if !ok {
+				return
+			}
+			select {
+			case ch <- Pair[T, U]{x, y}:
+			case <-s.done:
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// Close stops the PairIter and the Iters it was created from, like Iter.Close.
+//
+// Close 方法停止该 PairIter 以及创建它所依赖的迭代器，与 Iter.Close 相同。
+func (p PairIter[T, U]) Close() {
+	closeStage(p)
+}
+
+// Unzip splits p into an Iter of the First of every Pair and an Iter of the Second of every Pair.
+// The two Iters can be consumed independently: the elements that one of them has not yet consumed are
+// buffered, so reading only one of them never blocks, but the buffer of the other one grows without bound
+// if it is never consumed. Like Fork, Unzip only receives from p when one of them needs the next element,
+// so when both are read at the same pace, only the lag between them is buffered. p is closed once both Iters are closed.
+//
+// Unzip 函数将 p 拆分为两个迭代器，分别包含每个 Pair 的 First 和 Second。
+// 两个迭代器可以独立地遍历：其中一个尚未消费的元素会被缓存，因此只读取其中一个不会阻塞，
+// 但若另一个始终不被消费，它的缓存会无限增长。与 Fork 相同，只有在其中一方需要下一个元素时，Unzip 才会从 p 接收，
+// 因此当两者以相同的速度被读取时，只有它们之间的差距会被缓存。当两个迭代器都被关闭后，p 也会被关闭。
+func Unzip[T, U any](p PairIter[T, U]) (Iter[T], Iter[U]) {
+	firsts, sa := newStage[T]()
+	seconds, sb := newStage[U]()
+	go func() {
+		defer p.Close()
+		// a and b are set to nil once finished, a nil channel blocks forever which disables its cases in the select
+		a, b := firsts, seconds
+		doneA, doneB := sa.done, sb.done
+		var qa []T
+		var qb []U
+		in := p
+		for a != nil || b != nil {
+			var outA chan T
+			var outB chan U
+			var headA T
+			var headB U
+			if len(qa) > 0 {
+				outA, headA = a, qa[0]
+			}
+			if len(qb) > 0 {
+				outB, headB = b, qb[0]
+			}
+			// only receive from p when a side that is still open has nothing left to send
+			var recv PairIter[T, U]
+			if (a != nil && len(qa) == 0 && !isDone(sa)) || (b != nil && len(qb) == 0 && !isDone(sb)) {
+				recv = in
+			}
+			select {
+			case pair, ok := <-recv:
+				if !ok {
+					in = nil
+					break
+				}
+				if a != nil {
+					qa = append(qa, pair.First)
+				}
+				if b != nil {
+					qb = append(qb, pair.Second)
+				}
+			case outA <- headA:
+				qa = qa[1:]
+			case outB <- headB:
+				qb = qb[1:]
+			case <-doneA:
+				qa = nil
+			case <-doneB:
+				qb = nil
+			}
+			if a != nil && len(qa) == 0 && (in == nil || isDone(sa)) {
+				sa.finish()
+				a, doneA = nil, nil
+			}
+			if b != nil && len(qb) == 0 && (in == nil || isDone(sb)) {
+				sb.finish()
+				b, doneB = nil, nil
+			}
+		}
+	}()
+	return firsts, seconds
+}
+
+func isDone(s *stage) bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// PullIter is a pull-based iterator: every call of Next returns the next element, or false once it has ended.
+// Its combinators are plain function composition, with no goroutine or channel per stage,
+// so a pipeline of PullIters is much cheaper than the same pipeline of Iters. A PullIter is not safe for concurrent use,
+// and it is lazy like an Iter: nothing happens until Next is called.
+// Once Next has returned false, it keeps returning false.
+//
+// PullIter 类型是一个拉取式的迭代器：每次调用 Next 返回下一个元素；迭代器结束后返回 false。
+// 它的组合方法只是普通的函数组合，每一级都不需要 goroutine 和 channel，因此由 PullIter 组成的流水线比同样的 Iter 流水线开销小得多。
+// PullIter 不是并发安全的。与 Iter 一样，它是惰性的：在调用 Next 之前不会做任何事。Next 一旦返回 false，之后也会一直返回 false。
+type PullIter[T any] struct {
+	Next func() (T, bool)
+}
+
+// ToSeq turns the Iter into a range-over-func iterator of the standard library, yielding the elements of the Iter.
+// When the consumer stops early, e.g. by breaking out of a for range loop over it, the Iter is closed.
+// The returned iter.Seq can only be ranged over once, since the elements are consumed from the Iter.
+//
+// ToSeq 方法将迭代器转化为标准库中的 range-over-func 迭代器，依次产生迭代器中的元素。
+// 当使用者提前停止时（例如从遍历它的 for range 循环中 break），迭代器会被关闭。
+// 由于元素是从迭代器中消费的，返回的 iter.Seq 只能遍历一次。
+func (it Iter[T]) ToSeq() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for x := range it {
+			if !yield(x) {
+				it.Close()
+				return
+			}
+		}
+	}
+}
+
+// Pull turns the Iter into a PullIter receiving from it. Close the Iter if the PullIter is abandoned before it ends.
+//
+// Pull 方法将迭代器转化为从中接收元素的 PullIter。若在 PullIter 结束前放弃它，请关闭原先的迭代器。
+func (it Iter[T]) Pull() PullIter[T] {
+	return PullIter[T]{Next: func() (T, bool) {
+		x, ok := <-it
+		return x, ok
+	}}
+}
+
+// Chan turns the PullIter into an Iter, calling Next in a new goroutine.
+// Closing the Iter stops calling Next.
+//
+// Chan 方法将 PullIter 转化为迭代器，在一个新的 goroutine 中调用 Next。关闭该迭代器后便不再调用 Next。
+func (p PullIter[T]) Chan() Iter[T] {
+	ch, s := newStage[T]()
+	go func() {
+		defer s.finish()
+		for {
+			x, ok := p.Next()
+			if !ok || !send(s, ch, x) {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// Map creates a PullIter whose elements are projected from those of the original PullIter by applying fn.
+//
+// Map 方法生成一个新的 PullIter，并使用 fn 将原先 PullIter 中的元素映射到新的 PullIter 中。
+func (p PullIter[T]) Map(fn func(T) T) PullIter[T] {
+	return PullIter[T]{Next: func() (T, bool) {
+		x, ok := p.Next()
+		if !ok {
+			var zero T
+			return zero, false
+		}
+		return fn(x), true
+	}}
+}
+
+// Filter creates a PullIter which only contains the elements of the original PullIter that satisfy pred.
+//
+// Filter 方法生成一个新的 PullIter，只保留原先 PullIter 中满足 pred 条件的元素。
+func (p PullIter[T]) Filter(pred func(T) bool) PullIter[T] {
+	return PullIter[T]{Next: func() (T, bool) {
+		for {
+			x, ok := p.Next()
+			if !ok || pred(x) {
+				return x, ok
+			}
+		}
+	}}
+}
+
+// Take creates a PullIter that only contains the first at most n elements of the original PullIter,
+// calling its Next exactly min(n, length) times. Take panics if n is negative.
+//
+// Take 方法生成一个新的 PullIter，只包含原先 PullIter 中的最多前 n 个元素，恰好调用其 Next min(n, 长度) 次。
+// 若 n 为负数，此方法会 panic。
+func (p PullIter[T]) Take(n int) PullIter[T] {
+	if n < 0 {
+		panic("Take: n must not be negative")
+	}
+	return PullIter[T]{Next: func() (T, bool) {
+		if n == 0 {
+			var zero T
+			return zero, false
+		}
+		x, ok := p.Next()
+		if !ok {
+			n = 0
+			return x, false
+		}
+		n--
+		return x, true
+	}}
+}
+
+// Drop creates a PullIter that skips over the first at most n elements of the original PullIter.
+// Drop panics if n is negative.
+//
+// Drop 方法生成一个新的 PullIter，跳过原先 PullIter 中的最多前 n 个元素。若 n 为负数，此方法会 panic。
+func (p PullIter[T]) Drop(n int) PullIter[T] {
+	if n < 0 {
+		panic("Drop: n must not be negative")
+	}
+	return PullIter[T]{Next: func() (T, bool) {
+		for ; n > 0; n-- {
+			if x, ok := p.Next(); !ok {
+				n = 0
+				return x, false
+			}
+		}
+		return p.Next()
+	}}
+}
+
+// Reduce aggregates the elements of the PullIter by applying fn, starting from init.
+// DO NOT call this method on an infinite PullIter, or it results in an infinite loop.
+//
+// Reduce 方法从 init 开始，使用 fn 对 PullIter 中的元素进行加总。
+// 不要在无穷 PullIter 上调用此方法，否则会导致死循环。
+func (p PullIter[T]) Reduce(init T, fn func(T, T) T) T {
+	acc := init
+	for x, ok := p.Next(); ok; x, ok = p.Next() {
+		acc = fn(acc, x)
+	}
+	return acc
+}
+
+// Collect turns the PullIter to a slice.
+// DO NOT call this method on an infinite PullIter, or it results in an infinite loop.
+//
+// Collect 方法将 PullIter 转化成一个 slice。
+// 不要在无穷 PullIter 上调用此方法，否则会导致死循环。
+func (p PullIter[T]) Collect() []T {
+	var s []T
+	for x, ok := p.Next(); ok; x, ok = p.Next() {
+		s = append(s, x)
+	}
+	return s
+}
+
+// BatchIter is an iterator that passes the elements between its stages in batches rather than one at a time,
+// which saves most of the synchronization cost of a channel hop per element. Its methods behave like those of Iter,
+// preserving the order of the elements exactly, and Unbatch turns it back into an Iter.
+// The batches are owned by the receiver, so the stages may modify them in place.
+//
+// BatchIter 类型是一个在各级之间成批而不是逐个传递元素的迭代器，它可以省去每个元素一次 channel 传递的大部分同步开销。
+// 它的方法与 Iter 的相应方法行为相同，并严格保持元素的顺序；Unbatch 方法可以将其转化回迭代器。
+// 每一批元素都归接收者所有，因此各级可以原地修改它们。
+type BatchIter[T any] <-chan []T
+
+// Batched creates a BatchIter sending the elements of the Iter in batches of size elements,
+// the last batch holding whatever is left when the Iter ends. Batched panics if size is not positive.
+//
+// Batched 方法生成一个 BatchIter，将迭代器中的元素按每批 size 个发送，迭代器结束时剩余的元素作为最后一批发送。
+// 若 size 不是正数，此方法会 panic。
+func (it Iter[T]) Batched(size int) BatchIter[T] {
+	if size <= 0 {
+		panic(fmt.Sprintf("Batched: size = %d is not positive", size))
+	}
+	return newBatchStage([]interface{ Close() }{it}, func(send func([]T) bool) {
+		batch := make([]T, 0, size)
+		for x := range it {
+			batch = append(batch, x)
+			if len(batch) == size {
+				if !send(batch) {
+					return
+				}
+				batch = make([]T, 0, size)
+			}
+		}
+		if len(batch) > 0 {
+			send(batch)
+		}
+	})
+}
+
+// newBatchStage runs produce in the goroutine of a new stage of a BatchIter,
+// with a send function reporting false once the stage is cancelled.
+func newBatchStage[T any](upstreams []interface{ Close() }, produce func(send func([]T) bool)) BatchIter[T] {
+	ch := make(chan []T)
+	s := register(BatchIter[T](ch), func() { close(ch) }, -1, false, upstreams...)
+	go func() {
+		defer s.finish()
+		produce(func(batch []T) bool {
+			select {
+			case ch <- batch:
+				return true
+			case <-s.done:
+				return false
+			}
+		})
+	}()
+	return ch
+}
+
+// Close stops the BatchIter and every iterator it was created from, like Iter.Close.
+//
+// Close 方法停止该 BatchIter 以及创建它所依赖的所有迭代器，与 Iter.Close 相同。
+func (b BatchIter[T]) Close() {
+	closeStage(b)
+}
+
+// Map is like Iter.Map, applying fn to every element of every batch.
+//
+// Map 方法与 Iter.Map 相同，对每一批中的每个元素调用 fn。
+func (b BatchIter[T]) Map(fn func(T) T) BatchIter[T] {
+	return newBatchStage([]interface{ Close() }{b}, func(send func([]T) bool) {
+		for batch := range b {
+			for i, x := range batch {
+				batch[i] = fn(x)
+			}
+			if !send(batch) {
+				return
+			}
+		}
+	})
+}
+
+// Filter is like Iter.Filter. Batches left empty by pred are not sent.
+//
+// Filter 方法与 Iter.Filter 相同。被 pred 过滤为空的批次不会被发送。
+func (b BatchIter[T]) Filter(pred func(T) bool) BatchIter[T] {
+	return newBatchStage([]interface{ Close() }{b}, func(send func([]T) bool) {
+		for batch := range b {
+			kept := batch[:0]
+			for _, x := range batch {
+				if pred(x) {
+					kept = append(kept, x)
+				}
+			}
+			if len(kept) > 0 && !send(kept) {
+				return
+			}
+		}
+	})
+}
+
+// Reduce is like Iter.Reduce.
+// DO NOT call this method on an infinite BatchIter, or it results in an infinite loop.
+//
+// Reduce 方法与 Iter.Reduce 相同。
+// 不要在无穷 BatchIter 上调用此方法，否则会导致死循环。
+func (b BatchIter[T]) Reduce(init T, fn func(T, T) T) T {
+	acc := init
+	for batch := range b {
+		for _, x := range batch {
+			acc = fn(acc, x)
+		}
+	}
+	return acc
+}
+
+// Collect is like Iter.Collect.
+// DO NOT call this method on an infinite BatchIter, or it results in an infinite loop.
+//
+// Collect 方法与 Iter.Collect 相同。
+// 不要在无穷 BatchIter 上调用此方法，否则会导致死循环。
+func (b BatchIter[T]) Collect() []T {
+	var s []T
+	for batch := range b {
+		s = append(s, batch...)
 	}
 	return s
 }
+
+// Unbatch turns the BatchIter back into an Iter sending the elements one at a time.
+//
+// Unbatch 方法将 BatchIter 转化回逐个发送元素的迭代器。
+func (b BatchIter[T]) Unbatch() Iter[T] {
+	ch, s := newStage[T]()
+	s.upstreams = append(s.upstreams, b)
+	go func() {
+		defer s.finish()
+		for batch := range b {
+			for _, x := range batch {
+				if !send(s, ch, x) {
+					return
+				}
+			}
+		}
+	}()
+	return ch
+}
+
+// Cached is a replayable view of an Iter created by Memoize. Every Iter returned by its Iter method sends
+// the whole sequence of the original Iter from the start, while the original Iter is consumed only once:
+// the elements are received lazily, when a reader first needs them, and kept in memory for the other readers.
+// It is safe to read from any number of the Iters concurrently. Close a reader Iter when abandoning it,
+// and Close the Cached when no more readers are needed, to release the original Iter.
+//
+// Cached 类型是由 Memoize 创建的可重放的迭代器视图。其 Iter 方法返回的每个迭代器都从头发送原迭代器的完整序列，
+// 而原迭代器只被消费一次：元素在某个读者首次需要时才被惰性地接收，并保存在内存中供其他读者使用。
+// 并发地读取任意多个这样的迭代器都是安全的。放弃某个读者迭代器时请关闭它；不再需要读者时请关闭 Cached，以释放原迭代器。
+type Cached[T any] struct {
+	source  Iter[T]
+	mu      sync.Mutex
+	cond    *sync.Cond
+	buf     []T
+	pulling bool
+	ended   bool
+	// closed is closed by Close, which ends every reader
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// Memoize creates a Cached view of the Iter, which must not be received from directly afterwards.
+// All the elements received are kept in memory, so DO NOT read an infinite Iter to the end through it.
+//
+// Memoize 方法生成迭代器的 Cached 视图，此后不应再直接从原迭代器接收元素。
+// 所有接收到的元素都会保存在内存中，因此不要通过它将无穷迭代器读到末尾。
+func (it Iter[T]) Memoize() *Cached[T] {
+	c := &Cached[T]{source: it, closed: make(chan struct{})}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Close ends every reader Iter, including those created afterwards, and closes the original Iter.
+// Calling Close more than once is safe.
+//
+// Close 方法结束所有读者迭代器（包括此后创建的），并关闭原迭代器。多次调用 Close 是安全的。
+func (c *Cached[T]) Close() {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.source.Close()
+		c.wake()
+	})
+}
+
+// wake wakes the readers waiting in at, so that they check whether they have been closed.
+func (c *Cached[T]) wake() {
+	c.mu.Lock()
+	c.cond.Broadcast()
+	c.mu.Unlock()
+}
+
+// cachedReader is an upstream of every reader Iter of a Cached, so that closing a reader wakes it up in at.
+type cachedReader[T any] struct {
+	c *Cached[T]
+}
+
+func (r cachedReader[T]) Close() {
+	r.c.wake()
+}
+
+// Iter creates a new Iter sending the elements of the original Iter from the start.
+//
+// Iter 方法生成一个新的迭代器，从头发送原迭代器中的元素。
+func (c *Cached[T]) Iter() Iter[T] {
+	ch, s := newStage[T](cachedReader[T]{c})
+	go func() {
+		defer s.finish()
+		for i := 0; ; i++ {
+			x, ok := c.at(i, s)
+			if !ok || !send(s, ch, x) {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// at returns the element at index i for the reader of stage s, receiving it from the source if no reader has yet,
+// or false if the source has ended before it, or the reader or the Cached has been closed.
+// Only one reader receives from the source at a time, while the others wait for it to broadcast.
+func (c *Cached[T]) at(i int, s *stage) (T, bool) {
+	var zero T
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		select {
+		case <-c.closed:
+			return zero, false
+		case <-s.done:
+			return zero, false
+		default:
+		}
+		if i < len(c.buf) {
+			return c.buf[i], true
+		}
+		if c.ended {
+			return zero, false
+		}
+		if c.pulling {
+			c.cond.Wait()
+			continue
+		}
+		c.pulling = true
+		c.mu.Unlock()
+		var x T
+		ok, stopped := false, false
+		select {
+		case x, ok = <-c.source:
+		case <-s.done:
+			stopped = true
+		case <-c.closed:
+			stopped = true
+		}
+		c.mu.Lock()
+		c.pulling = false
+		// a reader that stops leaves the receiving to the others
+		if !stopped {
+			if ok {
+				c.buf = append(c.buf, x)
+			} else {
+				c.ended = true
+			}
+		}
+		c.cond.Broadcast()
+	}
+}
+
+// Fork splits the Iter into two Iters, each sending every remaining element of it.
+// The two can be consumed independently: the elements that the faster one has received are buffered
+// until the slower one receives them, so the buffer is proportional to the lag between them
+// rather than to the whole Iter, and an element is only received from the original Iter when one of them needs it.
+// Close a fork when abandoning it, so that it is no longer buffered for; otherwise the other fork is never blocked,
+// but the buffer keeps growing with everything it receives. The original Iter is closed once both forks are closed.
+//
+// Fork 方法将迭代器拆分为两个迭代器，它们都会发送原迭代器中剩余的每个元素。
+// 两个迭代器可以独立地遍历：较快的一方已接收的元素会被缓存，直到较慢的一方接收它们，因此缓存与两者之间的差距成正比，
+// 而不是与整个迭代器成正比；并且只有在其中一方需要时，才会从原迭代器接收元素。
+// 放弃一个分支时请关闭它，使之不再被缓存；否则另一个分支虽然不会被阻塞，但缓存会随它接收的所有元素而增长。
+// 当两个分支都被关闭后，原迭代器也会被关闭。
+func (it Iter[T]) Fork() (Iter[T], Iter[T]) {
+	size, exact := it.SizeHint()
+	forkA, sa := newSizedStage[T](size, exact)
+	forkB, sb := newSizedStage[T](size, exact)
+	sa.unbounded, sb.unbounded = it.Unbounded(), it.Unbounded()
+	go func() {
+		defer it.Close()
+		// a and b are set to nil once finished, a nil channel blocks forever which disables its cases in the select
+		a, b := forkA, forkB
+		doneA, doneB := sa.done, sb.done
+		// buf holds the elements not yet received by both, i and j are the indices of the next elements of a and b in it
+		var buf []T
+		var i, j int
+		var in <-chan T = it
+		for a != nil || b != nil {
+			var outA, outB chan T
+			var headA, headB T
+			if a != nil && i < len(buf) {
+				outA, headA = a, buf[i]
+			}
+			if b != nil && j < len(buf) {
+				outB, headB = b, buf[j]
+			}
+			var recv <-chan T
+			if (a != nil && i == len(buf)) || (b != nil && j == len(buf)) {
+				recv = in
+			}
+			select {
+			case x, ok := <-recv:
+				if !ok {
+					in = nil
+					break
+				}
+				buf = append(buf, x)
+			case outA <- headA:
+				i++
+			case outB <- headB:
+				j++
+			case <-doneA:
+			case <-doneB:
+			}
+			if a != nil && ((in == nil && i == len(buf)) || isDone(sa)) {
+				sa.finish()
+				a, doneA = nil, nil
+			}
+			if b != nil && ((in == nil && j == len(buf)) || isDone(sb)) {
+				sb.finish()
+				b, doneB = nil, nil
+			}
+			lo := len(buf)
+			if a != nil {
+				lo = min(lo, i)
+			}
+			if b != nil {
+				lo = min(lo, j)
+			}
+			buf, i, j = buf[lo:], i-lo, j-lo
+		}
+	}()
+	return forkA, forkB
+}
+
+// Res is an element of a ResIter: either a value, or the error that occurred while producing one.
+//
+// Res 类型是 ResIter 中的元素：要么是一个值，要么是生成值时发生的错误。
+type Res[T any] struct {
+	Val T
+	Err error
+}
+
+// ResIter is an iterator of Res, carrying the errors of a pipeline along with its values.
+// Its Map and Filter only apply to the values and pass the errors through untouched,
+// so the errors reach the end of the pipeline in their original positions.
+//
+// ResIter 类型是 Res 的迭代器，它将流水线中的错误与值一同传递。
+// 它的 Map 和 Filter 方法只作用于值，而将错误原样传递，因此错误会按原先的位置到达流水线的末端。
+type ResIter[T any] <-chan Res[T]
+
+// newResStage is like newStage for a ResIter.
+func newResStage[T any](upstreams ...interface{ Close() }) (chan Res[T], *stage) {
+	ch := make(chan Res[T])
+	return ch, register(ResIter[T](ch), func() { close(ch) }, -1, false, upstreams...)
+}
+
+// MapErr is like Map, but fn may fail, and its results and errors are sent as a ResIter.
+// Use the MapErr function to project to another type.
+//
+// MapErr 方法与 Map 相同，但 fn 可能失败，其结果与错误以 ResIter 的形式发送。若要映射为另一类型，请使用 MapErr 函数。
+func (it Iter[T]) MapErr(fn func(T) (T, error)) ResIter[T] {
+	return MapErr(it, fn)
+}
+
+// MapErr is like the Map function, but fn may fail, and its results and errors are sent as a ResIter.
+//
+// MapErr 函数与 Map 函数相同，但 fn 可能失败，其结果与错误以 ResIter 的形式发送。
+func MapErr[T, U any](it Iter[T], fn func(T) (U, error)) ResIter[U] {
+	ch, s := newResStage[U](it)
+	go func() {
+		defer s.finish()
+		for x := range it {
+			y, err := fn(x)
+			if !send(s, ch, Res[U]{y, err}) {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// Close stops the ResIter and every iterator it was created from, like Iter.Close.
+//
+// Close 方法停止该 ResIter 以及创建它所依赖的所有迭代器，与 Iter.Close 相同。
+func (r ResIter[T]) Close() {
+	closeStage(r)
+}
+
+// Map applies fn to the values of the ResIter, passing the errors through untouched.
+//
+// Map 方法对 ResIter 中的值调用 fn，并将错误原样传递。
+func (r ResIter[T]) Map(fn func(T) T) ResIter[T] {
+	ch, s := newResStage[T](r)
+	go func() {
+		defer s.finish()
+		for res := range r {
+			if res.Err == nil {
+				res.Val = fn(res.Val)
+			}
+			if !send(s, ch, res) {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// Filter only keeps the values of the ResIter that satisfy pred, passing the errors through untouched.
+//
+// Filter 方法只保留 ResIter 中满足 pred 条件的值，并将错误原样传递。
+func (r ResIter[T]) Filter(pred func(T) bool) ResIter[T] {
+	ch, s := newResStage[T](r)
+	go func() {
+		defer s.finish()
+		for res := range r {
+			if res.Err == nil && !pred(res.Val) {
+				continue
+			}
+			if !send(s, ch, res) {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// StopOnError creates a ResIter that ends right after sending the first error of the original one,
+// closing it without consuming the rest of it.
+//
+// StopOnError 方法生成一个新的 ResIter，它在发送原 ResIter 中的第一个错误后立即结束，并关闭原 ResIter 而不消费剩余的元素。
+func (r ResIter[T]) StopOnError() ResIter[T] {
+	ch, s := newResStage[T](r)
+	go func() {
+		defer s.finish()
+		for res := range r {
+			if !send(s, ch, res) || res.Err != nil {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// CollectErr collects the values of the ResIter to a slice, and returns it together with the first error, if any.
+// It consumes the whole ResIter, so the values after an error are collected too; use StopOnError to stop at it.
+// DO NOT call this method on an infinite ResIter, or it results in an infinite loop.
+//
+// CollectErr 方法将 ResIter 中的值收集到一个 slice 中，并将其与第一个错误（若有）一同返回。
+// 它会消费整个 ResIter，因此错误之后的值也会被收集；若要在错误处停止，请使用 StopOnError。
+// 不要在无穷 ResIter 上调用此方法，否则会导致死循环。
+func (r ResIter[T]) CollectErr() ([]T, error) {
+	var s []T
+	var first error
+	for res := range r {
+		if res.Err == nil {
+			s = append(s, res.Val)
+		} else if first == nil {
+			first = res.Err
+		}
+	}
+	return s, first
+}
+
+// Metrics collects the statistics of a stage created by Instrument. Its counters are updated atomically,
+// so Snapshot can be called while the pipeline runs. Use a separate Metrics for every instrumented stage.
+// The zero value is ready to use; set Clock before calling Instrument to replace time.Now, e.g. in tests.
+//
+// Metrics 类型收集由 Instrument 创建的一级的统计信息。它的计数器以原子方式更新，因此可以在流水线运行时调用 Snapshot。
+// 每个被监测的级应使用单独的 Metrics。零值即可使用；可以在调用 Instrument 之前设置 Clock 来替换 time.Now，例如在测试中。
+type Metrics struct {
+	Clock func() time.Time
+
+	name        atomic.Value
+	elements    atomic.Int64
+	first       atomic.Int64
+	last        atomic.Int64
+	blockedSend atomic.Int64
+	blockedRecv atomic.Int64
+}
+
+// MetricsSnapshot holds the statistics of an instrumented stage at some point.
+// BlockedRecv is the time spent waiting for the original Iter, and BlockedSend the time spent waiting for the consumer.
+//
+// MetricsSnapshot 类型保存被监测的级在某一时刻的统计信息。
+// BlockedRecv 是等待原迭代器所花的时间，BlockedSend 是等待使用者所花的时间。
+type MetricsSnapshot struct {
+	Name        string
+	Elements    int64
+	First, Last time.Time
+	BlockedSend time.Duration
+	BlockedRecv time.Duration
+}
+
+// Instrument creates a new Iter forwarding the elements of the original Iter unchanged, recording into m,
+// under the given name, how many elements it forwarded, when it forwarded the first and the last of them,
+// and how long it was blocked receiving and sending.
+//
+// Instrument 方法生成一个新的迭代器，原样转发原迭代器中的元素，并以给定的名称在 m 中记录：
+// 转发的元素个数、转发第一个和最后一个元素的时间，以及在接收和发送时被阻塞的时长。
+func (it Iter[T]) Instrument(name string, m *Metrics) Iter[T] {
+	m.name.Store(name)
+	now := m.Clock
+	if now == nil {
+		now = time.Now
+	}
+	size, exact := it.SizeHint()
+	ch, s := newSizedStage[T](size, exact, it)
+	s.unbounded = it.Unbounded()
+	go func() {
+		defer s.finish()
+		for {
+			t0 := now()
+			x, ok := <-it
+			t1 := now()
+			m.blockedRecv.Add(int64(t1.Sub(t0)))
+			if !ok || !send(s, ch, x) {
+				return
+			}
+			t2 := now()
+			m.blockedSend.Add(int64(t2.Sub(t1)))
+			m.elements.Add(1)
+			m.first.CompareAndSwap(0, t2.UnixNano())
+			m.last.Store(t2.UnixNano())
+		}
+	}()
+	return ch
+}
+
+// Snapshot returns the current statistics recorded in m. First and Last are zero until an element is forwarded.
+//
+// Snapshot 方法返回 m 中当前记录的统计信息。在转发第一个元素之前，First 和 Last 为零值。
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	name, _ := m.name.Load().(string)
+	snap := MetricsSnapshot{
+		Name:        name,
+		Elements:    m.elements.Load(),
+		BlockedSend: time.Duration(m.blockedSend.Load()),
+		BlockedRecv: time.Duration(m.blockedRecv.Load()),
+	}
+	if first := m.first.Load(); first != 0 {
+		snap.First = time.Unix(0, first)
+	}
+	if last := m.last.Load(); last != 0 {
+		snap.Last = time.Unix(0, last)
+	}
+	return snap
+}
+
+// traceMu serializes the writes of all the Trace stages, so that they may share a writer and their lines never interleave.
+var traceMu sync.Mutex
+
+// Trace creates a new Iter forwarding the elements of the original Iter unchanged, writing a line to w for each of them,
+// like "label: 42", before forwarding it, and a final "label: <closed>" when it ends.
+// The lines of all Trace stages are written one at a time, so they can share a writer that is not safe for concurrent use.
+// If w is nil or io.Discard, Trace returns the original Iter itself.
+//
+// Trace 方法生成一个新的迭代器，原样转发原迭代器中的元素，并在转发每个元素之前向 w 写入一行，例如 "label: 42"，
+// 在结束时写入最后一行 "label: <closed>"。所有 Trace 级的行都是逐行写入的，因此它们可以共享一个非并发安全的 writer。
+// 若 w 为 nil 或 io.Discard，Trace 直接返回原迭代器。
+func (it Iter[T]) Trace(w io.Writer, label string) Iter[T] {
+	if w == nil || w == io.Discard {
+		return it
+	}
+	trace := func(format string, a ...interface{}) {
+		traceMu.Lock()
+		defer traceMu.Unlock()
+		fmt.Fprintf(w, format, a...)
+	}
+	size, exact := it.SizeHint()
+	ch, s := newSizedStage[T](size, exact, it)
+	s.unbounded = it.Unbounded()
+	go func() {
+		defer s.finish()
+		defer trace("%s: <closed>\n", label)
+		for x := range it {
+			trace("%s: %v\n", label, x)
+			if !send(s, ch, x) {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// stringPreview and stringTimeout bound the elements String shows, and the time it waits for them.
+const (
+	stringPreview = 5
+	stringTimeout = 10 * time.Millisecond
+)
+
+// String describes the Iter by its SizeHint and Unbounded, and by up to 5 of its next elements, e.g.
+// "Iter[int](10 elements, 5 buffered: [0 1 2 3 4] ...)", "Iter[int](unbounded, 5 buffered: [0 1 2 3 4] ...)"
+// or "Iter[int](unknown size)". The elements are held back in a replay buffer of the Iter, so that they are still
+// sent to the consumer, in order and before any other; String does not consume them, and calling it again
+// shows the same ones. It waits at most 10ms for them, so it returns in time for an Iter that stalls or has
+// ended, and the trailing "..." means that more elements are known to follow. Only an Iter created by
+// this package has a replay buffer, so String shows no elements of a converted channel.
+//
+// String 方法根据 SizeHint 和 Unbounded，以及迭代器接下来最多 5 个元素来描述迭代器，例如
+// "Iter[int](10 elements, 5 buffered: [0 1 2 3 4] ...)"、"Iter[int](unbounded, 5 buffered: [0 1 2 3 4] ...)"
+// 或 "Iter[int](unknown size)"。这些元素被暂存在迭代器的重放缓冲区中，因此它们仍会按原先的顺序、先于其他元素发送给消费者；
+// String 不会消费它们，再次调用时也会展示相同的元素。它最多等待 10 毫秒，因此对于停滞或已结束的迭代器也能及时返回；
+// 末尾的 "..." 表示已知还有更多元素。只有本包创建的迭代器才有重放缓冲区，因此 String 不会展示直接转换得到的 channel 的元素。
+func (it Iter[T]) String() string {
+	var zero T
+	name := fmt.Sprintf("Iter[%T]", zero)
+	var desc string
+	switch n, exact := it.SizeHint(); {
+	case it.Unbounded():
+		desc = "unbounded"
+	case n < 0:
+		desc = "unknown size"
+	case exact:
+		desc = fmt.Sprintf("%d elements", n)
+	default:
+		desc = fmt.Sprintf("at most %d elements", n)
+	}
+	// one more element than shown tells whether more follow
+	buffered := it.peek(stringPreview+1, stringTimeout)
+	switch {
+	case len(buffered) > stringPreview:
+		return fmt.Sprintf("%s(%s, %d buffered: %v ...)", name, desc, stringPreview, buffered[:stringPreview])
+	case len(buffered) > 0:
+		return fmt.Sprintf("%s(%s, %d buffered: %v)", name, desc, len(buffered), buffered)
+	default:
+		return fmt.Sprintf("%s(%s)", name, desc)
+	}
+}
+
+// peek holds back the next elements of the Iter until there are k of them, or until it ends, stalls for timeout
+// or is closed, and returns the first k elements held back. They are sent again in order before any other.
+func (it Iter[T]) peek(k int, timeout time.Duration) []T {
+	v, ok := stages.Load(it)
+	if !ok || v.(*stage).peek == nil {
+		return nil
+	}
+	s := v.(*stage)
+	s.peekMu.Lock()
+	defer s.peekMu.Unlock()
+	defer func() { go s.flush() }()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	s.mu.Lock()
+	flushing := s.flushing
+	s.mu.Unlock()
+	expired := false
+	if flushing {
+		// a running flush is back in emit once it has dropped the element it sent before from held
+		select {
+		case s.synced <- struct{}{}:
+		case <-timer.C:
+			expired = true
+		case <-s.done:
+		}
+	}
+ask:
+	for !expired {
+		s.mu.Lock()
+		n := len(s.held)
+		s.mu.Unlock()
+		if n >= k {
+			break
+		}
+		ack := make(chan struct{})
+		select {
+		case s.peek <- ack:
+			<-ack
+		case <-timer.C:
+			break ask
+		case <-s.done:
+			break ask
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var held []T
+	for _, x := range s.held[:min(k, len(s.held))] {
+		held = append(held, x.(T))
+	}
+	return held
+}
+
+// RangeState describes the integers from From up to but excluding To by Step, like the arguments of RangeStep.
+//
+// RangeState 类型描述从 From 开始、以 Step 为步长、直到 To（不包含）的整数，与 RangeStep 的参数相同。
+type RangeState struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+	Step int `json:"step"`
+}
+
+// advance returns the range left after sending its first n elements.
+func (r RangeState) advance(n int) RangeState {
+	if size := rangeSize(r.From, r.To, r.Step); size >= 0 && n >= size {
+		return RangeState{r.To, r.To, r.Step}
+	}
+	return RangeState{r.From + n*r.Step, r.To, r.Step}
+}
+
+// rangeOf returns the range the Iter sends, see stage.rng, or nil if it is not known.
+func rangeOf[T any](it Iter[T]) *RangeState {
+	if s, ok := stages.Load(it); ok {
+		return s.(*stage).rng
+	}
+	return nil
+}
+
+// CheckpointState is the progress of an Iter saved by Checkpoint. It is meant to be persisted,
+// e.g. with encoding/json, so that a pipeline can resume after a crash without processing the same elements again.
+//
+// CheckpointState 类型是由 Checkpoint 保存的迭代器的进度。它应当被持久化（例如使用 encoding/json），
+// 使流水线在崩溃后能够继续运行，而无需再次处理相同的元素。
+type CheckpointState struct {
+	// Emitted is the number of elements sent so far.
+	//
+	// Emitted 是目前为止已发送的元素个数。
+	Emitted int `json:"emitted"`
+	// Range is the part of the range not sent yet if the Iter was created by Range, RangeInclusive or RangeStep,
+	// possibly followed by Map, or nil otherwise. ResumeRange continues from it.
+	//
+	// 若迭代器由 Range、RangeInclusive 或 RangeStep 创建（之后可以经过 Map），Range 是尚未发送的那部分区间，
+	// 否则为 nil。ResumeRange 从它继续。
+	Range *RangeState `json:"range,omitempty"`
+}
+
+// ErrNotResumable is returned by ResumeRange when the CheckpointState does not record a range to resume.
+//
+// 当 CheckpointState 没有记录可以继续的区间时，ResumeRange 函数返回 ErrNotResumable。
+var ErrNotResumable = errors.New("checkpoint state is not resumable")
+
+// Checkpoint creates a ResIter forwarding the elements of the Iter, which calls save with the progress so far
+// after every every elements have been received from it, and once more when the Iter ends, unless the ResIter
+// has been closed. An element counts
+// as emitted once it has been received, so a crash while processing it loses it instead of repeating it.
+// If save fails, its error is sent after the last element and the ResIter ends, closing the original Iter.
+// Map the elements before Checkpoint, so that only the elements processed are counted.
+// Checkpoint panics if every is not positive.
+//
+// Checkpoint 方法生成一个转发原迭代器元素的 ResIter，每当有 every 个元素被接收后，以及原迭代器结束时，
+// 它都会以目前为止的进度调用 save（除非 ResIter 已被关闭）。元素一旦被接收即计为已发送，因此在处理它时崩溃会丢失该元素，而不是重复处理它。
+// 若 save 失败，它的错误会在最后一个元素之后发送，然后 ResIter 结束并关闭原迭代器。
+// 请在 Checkpoint 之前使用 Map 处理元素，使只有处理过的元素被计数。若 every 不是正数，此方法会 panic。
+func (it Iter[T]) Checkpoint(every int, save func(state CheckpointState) error) ResIter[T] {
+	if every <= 0 {
+		panic(fmt.Sprintf("Checkpoint: every = %d is not positive", every))
+	}
+	rng := rangeOf(it)
+	state := func(emitted int) CheckpointState {
+		state := CheckpointState{Emitted: emitted}
+		if rng != nil {
+			left := rng.advance(emitted)
+			state.Range = &left
+		}
+		return state
+	}
+	ch, s := newResStage[T](it)
+	go func() {
+		defer s.finish()
+		emitted := 0
+		for x := range it {
+			if !send(s, ch, Res[T]{Val: x}) {
+				return
+			}
+			emitted++
+			if emitted%every != 0 {
+				continue
+			}
+			if err := save(state(emitted)); err != nil {
+				send(s, ch, Res[T]{Err: err})
+				return
+			}
+		}
+		// a closed ResIter saves nothing more, and the last save may already have recorded the final progress
+		if !isDone(s) && (emitted%every != 0 || emitted == 0) {
+			if err := save(state(emitted)); err != nil {
+				send(s, ch, Res[T]{Err: err})
+			}
+		}
+	}()
+	return ch
+}
+
+// ResumeRange creates an Iter of the part of the range recorded in state not sent yet, so that a pipeline
+// checkpointed over a Range can continue where it left off. It returns ErrNotResumable if state has no range.
+//
+// ResumeRange 函数生成一个迭代器，包含 state 中记录的区间尚未发送的部分，使得在 Range 上使用 Checkpoint 的流水线
+// 可以从中断处继续。若 state 中没有区间，则返回 ErrNotResumable。
+func ResumeRange(state CheckpointState) (Iter[int], error) {
+	r := state.Range
+	if r == nil || r.Step == 0 {
+		return nil, ErrNotResumable
+	}
+	return RangeStep(r.From, r.To, r.Step), nil
+}
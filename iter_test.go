@@ -1,176 +1,3547 @@
-package main
+package goiter_test
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"iter"
+	"math"
+	"math/rand"
 	"reflect"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	. "github.com/CoderYihaoWang/goiter"
+	"github.com/CoderYihaoWang/goiter/itertest"
 )
 
 func TestRange(t *testing.T) {
-	from, to := 10, 100
-	var expected, actual []int
-	for i := from; i < to; i++ {
+	var expected []int
+	for i := 10; i < 100; i++ {
 		expected = append(expected, i)
 	}
-	it := Range(from, to)
-	for x := range it {
-		actual = append(actual, x)
+	itertest.AssertEqual(t, Range(10, 100), expected)
+}
+
+func TestRangeInclusive(t *testing.T) {
+	tests := []struct {
+		from, to int
+		expected []int
+	}{
+		{1, 5, []int{1, 2, 3, 4, 5}},
+		{3, 3, []int{3}},
+		{5, 1, nil},
+		{math.MaxInt - 2, math.MaxInt, []int{math.MaxInt - 2, math.MaxInt - 1, math.MaxInt}},
 	}
-	if !reflect.DeepEqual(expected, actual) {
-		t.Errorf("Range(%d, %d): expecting %v, got %v", from, to, expected, actual)
+	for _, test := range tests {
+		actual := RangeInclusive(test.from, test.to).Collect()
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf("RangeInclusive(%d, %d): expecting %v, got %v", test.from, test.to, test.expected, actual)
+		}
 	}
 }
 
-func TestSeq(t *testing.T) {
-	end := 100
-	var expected, actual []int
-	for i := 0; i < end; i++ {
-		expected = append(expected, i)
+func TestRangeStep(t *testing.T) {
+	tests := []struct {
+		from, to, step int
+		expected       []int
+	}{
+		{0, 10, 3, []int{0, 3, 6, 9}},
+		{10, 0, -2, []int{10, 8, 6, 4, 2}},
+		{5, 5, 1, nil},
+		{10, 0, 1, nil},
+		{0, 10, -1, nil},
+		{math.MaxInt - 5, math.MaxInt, 2, []int{math.MaxInt - 5, math.MaxInt - 3, math.MaxInt - 1}},
+		{math.MinInt + 5, math.MinInt, -2, []int{math.MinInt + 5, math.MinInt + 3, math.MinInt + 1}},
+	}
+	for _, test := range tests {
+		actual := RangeStep(test.from, test.to, test.step).Collect()
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf("RangeStep(%d, %d, %d): expecting %v, got %v", test.from, test.to, test.step, test.expected, actual)
+		}
 	}
+}
+
+func TestRangeStepZeroPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("RangeStep(0, 10, 0): expecting a panic")
+		}
+	}()
+	RangeStep(0, 10, 0)
+}
+
+func TestSeq(t *testing.T) {
 	it := Seq()
+	var actual []int
 	for x := range it {
-		if x == end {
+		if x == 100 {
 			break
 		}
 		actual = append(actual, x)
 	}
-	if !reflect.DeepEqual(expected, actual) {
-		t.Errorf("Seq (taking the first %d): expectiing %v, got %v", end, expected, actual)
+	it.Close()
+	if expected := ints(100); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Seq (taking the first 100): expecting %v, got %v", expected, actual)
+	}
+}
+
+func TestRepeat(t *testing.T) {
+	expected := []int{7, 7, 7, 7, 7}
+	itertest.AssertEqual(t, Repeat(7).Take(5), expected)
+}
+
+func TestRepeatZipAddsConstant(t *testing.T) {
+	k := 10
+	expected := []int{10, 11, 12, 13, 14}
+	// Repeat must be stopped once Zip ends with the shorter Iter
+	itertest.CheckNoLeaks(t, func() {
+		var actual []int
+		for p := range Zip(Repeat(k), itertest.FromSlice(ints(5))) {
+			actual = append(actual, p.First+p.Second)
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("Zip(Repeat(%d), %v) added: expecting %v, got %v", k, ints(5), expected, actual)
+		}
+	})
+}
+
+func TestRepeatN(t *testing.T) {
+	tests := []struct {
+		x, n     int
+		expected []int
+	}{
+		{7, 3, []int{7, 7, 7}},
+		{7, 0, nil},
+		{7, -1, nil},
+	}
+	for _, test := range tests {
+		actual := RepeatN(test.x, test.n).Collect()
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf("RepeatN(%d, %d): expecting %v, got %v", test.x, test.n, test.expected, actual)
+		}
 	}
 }
 
 func TestTakeIterLargerThanLimit(t *testing.T) {
-	size, limit := 100, 50
-	it := makeIter(size)
-	var expected, actual []int
-	for i := 0; i < limit; i++ {
-		expected = append(expected, i)
+	itertest.AssertEqual(t, itertest.FromSlice(ints(100)).Take(50), ints(50))
+}
+
+func TestTakeIterSmallerThanLimit(t *testing.T) {
+	itertest.AssertEqual(t, itertest.FromSlice(ints(50)).Take(100), ints(50))
+}
+
+func TestDropIterLargerThanLimit(t *testing.T) {
+	itertest.AssertEqual(t, itertest.FromSlice(ints(100)).Drop(50), ints(100)[50:])
+}
+
+func TestDropIterSmallerThanLimit(t *testing.T) {
+	itertest.AssertEqual(t, itertest.FromSlice(ints(50)).Drop(100), nil)
+}
+
+func TestCollect(t *testing.T) {
+	if expected, actual := ints(100), itertest.FromSlice(ints(100)).Collect(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Collect(): expecting %v, got %v", expected, actual)
 	}
-	for x := range it.Take(limit) {
-		actual = append(actual, x)
+}
+
+func TestMap(t *testing.T) {
+	square := func(x int) int { return x * x }
+	itertest.AssertEqual(t, itertest.FromSlice(ints(10)).Map(square), []int{0, 1, 4, 9, 16, 25, 36, 49, 64, 81})
+}
+
+func TestFilter(t *testing.T) {
+	isEven := func(x int) bool { return x%2 == 0 }
+	itertest.AssertEqual(t, itertest.FromSlice(ints(10)).Filter(isEven), []int{0, 2, 4, 6, 8})
+}
+
+func TestReduce(t *testing.T) {
+	add := func(acc, cur int) int { return acc + cur }
+	if expected, actual := 45, itertest.FromSlice(ints(10)).Reduce(0, add); expected != actual {
+		t.Errorf("Reduce(add): expecting %d, got %d", expected, actual)
 	}
+}
 
-	if !reflect.DeepEqual(expected, actual) {
-		t.Errorf("Take(%d), size = %d: expecting %v, got %v", limit, size, expected, actual)
+func TestFromChan(t *testing.T) {
+	ch := make(chan int)
+	go func() {
+		defer close(ch)
+		for i := 0; i < 10; i++ {
+			ch <- i
+		}
+	}()
+	expected := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	itertest.AssertEqual(t, FromChan(ch), expected)
+}
+
+func TestFromChanDrainClosedByProducer(t *testing.T) {
+	size, max := 5, 10
+	expected := ints(size)
+	itertest.AssertEqual(t, FromChanDrain(itertest.FromSlice(ints(size)), max), expected)
+}
+
+func TestFromChanDrainCappedByMax(t *testing.T) {
+	ch := make(chan int)
+	go func() {
+		// never closed
+		for i := 0; ; i++ {
+			ch <- i
+		}
+	}()
+	expected := []int{0, 1, 2, 3, 4}
+	itertest.AssertEqual(t, FromChanDrain(ch, 5), expected)
+}
+
+func TestFromFunc(t *testing.T) {
+	n := 0
+	next := func() (int, bool) {
+		n++
+		return n, n <= 5
+	}
+	expected := []int{1, 2, 3, 4, 5}
+	itertest.AssertEqual(t, FromFunc(next), expected)
+	if n != 6 {
+		t.Errorf("FromFunc: expecting next to be called 6 times, got %d", n)
 	}
 }
 
-func TestTakeIterSmallerThanLimit(t *testing.T) {
-	size, limit := 50, 100
-	it := makeIter(size)
-	var expected, actual []int
-	for i := 0; i < size; i++ {
-		expected = append(expected, i)
+func TestFromFuncEmpty(t *testing.T) {
+	calls := 0
+	next := func() (int, bool) {
+		calls++
+		return 0, false
 	}
-	for x := range it.Take(limit) {
-		actual = append(actual, x)
+	if actual := FromFunc(next).Collect(); actual != nil {
+		t.Errorf("FromFunc (immediately false): expecting an empty Iter, got %v", actual)
+	}
+	if calls != 1 {
+		t.Errorf("FromFunc (immediately false): expecting next to be called once, got %d", calls)
 	}
+}
 
-	if !reflect.DeepEqual(expected, actual) {
-		t.Errorf("Take(%d), size = %d: expecting %v, got %v", limit, size, expected, actual)
+func TestFromFuncRecover(t *testing.T) {
+	n := 0
+	next := func() (int, bool) {
+		n++
+		if n > 3 {
+			panic("boom")
+		}
+		return n, true
+	}
+	recovered := make(chan interface{}, 1)
+	expected := []int{1, 2, 3}
+	itertest.AssertEqual(t, FromFuncRecover(next, func(r interface{}) { recovered <- r }), expected)
+	if r := <-recovered; r != "boom" {
+		t.Errorf("FromFuncRecover: expecting the panic value %q, got %v", "boom", r)
 	}
 }
 
-func TestDropIterLargerThanLimit(t *testing.T) {
-	size, limit := 100, 50
-	it := makeIter(size)
-	var expected, actual []int
-	for i := limit; i < size; i++ {
-		expected = append(expected, i)
+func TestFromReader(t *testing.T) {
+	r := strings.NewReader("1 2\t3\n 4  5 \n\n")
+	expected := []int{1, 2, 3, 4, 5}
+	itertest.AssertEqual(t, FromReader(r), expected)
+}
+
+func TestFromReaderStopsAtMalformedToken(t *testing.T) {
+	r := strings.NewReader("1 2 x 3")
+	expected := []int{1, 2}
+	itertest.AssertEqual(t, FromReader(r), expected)
+}
+
+func TestFromReaderFunc(t *testing.T) {
+	r := strings.NewReader("1 x 2 3.5 3 stop 4")
+	var bad []string
+	onErr := func(token string, err error) bool {
+		bad = append(bad, token)
+		return token != "stop"
 	}
-	for x := range it.Drop(limit) {
-		actual = append(actual, x)
+	expected := []int{1, 2, 3}
+	itertest.AssertEqual(t, FromReaderFunc(r, onErr), expected)
+	if expectedBad := []string{"x", "3.5", "stop"}; !reflect.DeepEqual(expectedBad, bad) {
+		t.Errorf("FromReaderFunc: expecting onErr to be called with %v, got %v", expectedBad, bad)
 	}
+}
 
-	if !reflect.DeepEqual(expected, actual) {
-		t.Errorf("Drop(%d), size = %d: expecting %v, got %v", limit, size, expected, actual)
+func TestFromBinary(t *testing.T) {
+	expected := []int{0, 1, -1, 300, -300, math.MaxInt64, math.MinInt64}
+	itertest.AssertEqual(t, FromBinary(bytes.NewReader(encodeVarints(expected))), expected)
+}
+
+func TestFromBinaryFuncTruncated(t *testing.T) {
+	data := encodeVarints([]int{1, 2, math.MaxInt64})
+	data = data[:len(data)-1]
+	var errs []error
+	expected := []int{1, 2}
+	itertest.AssertEqual(t, FromBinaryFunc(bytes.NewReader(data), func(err error) { errs = append(errs, err) }), expected)
+	if len(errs) != 1 || errs[0] != io.ErrUnexpectedEOF {
+		t.Errorf("FromBinaryFunc (truncated): expecting [%v], got %v", io.ErrUnexpectedEOF, errs)
 	}
 }
 
-func TestDropIterSmallerThanLimit(t *testing.T) {
-	size, limit := 50, 100
-	it := makeIter(size)
-	var expected, actual []int // expected will remain nil
-	for x := range it.Drop(limit) {
-		actual = append(actual, x)
+func encodeVarints(s []int) []byte {
+	var data []byte
+	buf := make([]byte, binary.MaxVarintLen64)
+	for _, x := range s {
+		n := binary.PutVarint(buf, int64(x))
+		data = append(data, buf[:n]...)
 	}
+	return data
+}
 
-	if !reflect.DeepEqual(expected, actual) {
-		t.Errorf("Drop(%d), size = %d: expecting %v, got %v", limit, size, expected, actual)
+func TestFromJSON(t *testing.T) {
+	dec := json.NewDecoder(strings.NewReader(" [1, 2, -3, 9007199254740993] "))
+	expected := []int{1, 2, -3, 9007199254740993}
+	itertest.AssertEqual(t, FromJSON(dec), expected)
+}
+
+func TestFromJSONFuncInvalid(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []int
+	}{
+		{`{"a": 1}`, nil},
+		{`[1, [2], 3]`, []int{1}},
+		{`[1, 2.5, 3]`, []int{1}},
+		{`[1, "2", 3]`, []int{1}},
+		{`[1, 2`, []int{1, 2}},
+	}
+	for _, test := range tests {
+		var errs []error
+		dec := json.NewDecoder(strings.NewReader(test.input))
+		actual := FromJSONFunc(dec, func(err error) { errs = append(errs, err) }).Collect()
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf("FromJSONFunc(%s): expecting %v, got %v", test.input, test.expected, actual)
+		}
+		if len(errs) != 1 {
+			t.Errorf("FromJSONFunc(%s): expecting exactly one error, got %v", test.input, errs)
+		}
 	}
 }
 
-func TestCollect(t *testing.T) {
-	size := 100
-	it := makeIter(size)
-	var expected, actual []int
-	for i := 0; i < size; i++ {
-		expected = append(expected, i)
+func TestFromJSONLargeArray(t *testing.T) {
+	size := 1 << 20
+	r, w := io.Pipe()
+	go func() {
+		bw := bufio.NewWriter(w)
+		bw.WriteString("[")
+		for i := 0; i < size; i++ {
+			if i > 0 {
+				bw.WriteString(",")
+			}
+			fmt.Fprint(bw, i)
+		}
+		bw.WriteString("]")
+		bw.Flush()
+		w.Close()
+	}()
+	// the live heap, sampled a few times while the array is streamed, must not grow with it:
+	// buffering the input or the decoded elements would take several MiB
+	liveHeap := func() uint64 {
+		var m runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&m)
+		return m.HeapAlloc
+	}
+	before, peak := liveHeap(), uint64(0)
+	expected := size * (size - 1) / 2
+	actual := FromJSON(json.NewDecoder(r)).Reduce(0, func(acc, cur int) int {
+		if cur%(size/8) == 0 {
+			if h := liveHeap(); h > peak {
+				peak = h
+			}
+		}
+		return acc + cur
+	})
+	if expected != actual {
+		t.Errorf("FromJSON (%d elements): expecting sum %d, got %d", size, expected, actual)
+	}
+	if limit := uint64(1 << 20); peak > before+limit {
+		t.Errorf("FromJSON (%d elements): expecting the live heap to grow by at most %d bytes, got %d", size, limit, peak-before)
+	}
+}
+
+func TestFromCSV(t *testing.T) {
+	input := "id,name\n1,a\n\"2\",\"b, c\"\n3,d\n"
+	r := csv.NewReader(strings.NewReader(input))
+	if _, err := r.Read(); err != nil {
+		t.Fatalf("reading the header: %v", err)
 	}
-	actual = it.Collect()
+	expected := []int{1, 2, 3}
+	itertest.AssertEqual(t, FromCSV(r, 0), expected)
+}
 
-	if !reflect.DeepEqual(expected, actual) {
-		t.Errorf("Drop(): expecting %v, got %v", expected, actual)
+func TestFromCSVFunc(t *testing.T) {
+	input := "a,1\nb,x\nc\nd,4\n"
+	r := csv.NewReader(strings.NewReader(input))
+	r.FieldsPerRecord = -1
+	var lines []int
+	onErr := func(line int, err error) bool {
+		lines = append(lines, line)
+		return true
+	}
+	expected := []int{1, 4}
+	itertest.AssertEqual(t, FromCSVFunc(r, 1, onErr), expected)
+	if expectedLines := []int{2, 3}; !reflect.DeepEqual(expectedLines, lines) {
+		t.Errorf("FromCSVFunc: expecting errors on lines %v, got %v", expectedLines, lines)
 	}
 }
 
-func TestMap(t *testing.T) {
-	size := 10
-	it := makeIter(size)
-	double := func(x int) int { return x * x }
-	var expected, actual []int
+func TestFromCSVStopsAtMalformedRow(t *testing.T) {
+	input := "1\n2\n\"3\n4\n"
+	r := csv.NewReader(strings.NewReader(input))
+	expected := []int{1, 2}
+	itertest.AssertEqual(t, FromCSV(r, 0), expected)
+}
+
+func TestRandom(t *testing.T) {
+	seed, lo, hi, size := int64(42), -10, 10, 20
+	ref := rand.New(rand.NewSource(seed))
+	var expected []int
 	for i := 0; i < size; i++ {
-		expected = append(expected, double(i))
+		expected = append(expected, lo+ref.Intn(hi-lo))
 	}
-	for x := range it.Map(double) {
-		actual = append(actual, x)
+	itertest.AssertEqual(t, Random(rand.New(rand.NewSource(seed)), lo, hi).Take(size), expected)
+}
+
+func TestRandomBounds(t *testing.T) {
+	lo, hi, size := 3, 7, 10000
+	for x := range Random(rand.New(rand.NewSource(1)), lo, hi).Take(size) {
+		if x < lo || x >= hi {
+			t.Fatalf("Random(%d, %d): got %d out of bounds", lo, hi, x)
+		}
 	}
+}
 
-	if !reflect.DeepEqual(expected, actual) {
-		t.Errorf("Map(double): expecting %v, got %v", expected, actual)
+func TestRandomWideRange(t *testing.T) {
+	// bothSigns is whether the range is wide on both sides of 0, so that 1000 draws surely have both signs
+	tests := []struct {
+		lo, hi    int
+		bothSigns bool
+	}{
+		{math.MinInt, math.MaxInt, true},
+		{math.MinInt / 2, math.MaxInt/2 + 2, true},
+		{-1, math.MaxInt, false},
+		{math.MinInt, 1, false},
+	}
+	for _, test := range tests {
+		negative, positive := false, false
+		for x := range Random(rand.New(rand.NewSource(1)), test.lo, test.hi).Take(1000) {
+			if x < test.lo || x >= test.hi {
+				t.Fatalf("Random(%d, %d): got %d out of bounds", test.lo, test.hi, x)
+			}
+			negative, positive = negative || x < 0, positive || x > 0
+		}
+		if test.bothSigns && (!negative || !positive) {
+			t.Errorf("Random(%d, %d): expecting both negative and positive elements in 1000 draws", test.lo, test.hi)
+		}
 	}
 }
 
-func TestFilter(t *testing.T) {
-	size := 10
-	it := makeIter(size)
+func TestRandomComposes(t *testing.T) {
 	isEven := func(x int) bool { return x%2 == 0 }
-	var expected, actual []int
-	for i := 0; i < size; i++ {
-		if isEven(i) {
-			expected = append(expected, i)
+	actual := Random(rand.New(rand.NewSource(1)), 0, 100).Filter(isEven).Take(10).Collect()
+	if len(actual) != 10 {
+		t.Fatalf("Random.Filter.Take(10): expecting 10 elements, got %v", actual)
+	}
+	for _, x := range actual {
+		if !isEven(x) {
+			t.Errorf("Random.Filter(isEven): got odd element %d", x)
 		}
 	}
-	for x := range it.Filter(isEven) {
-		actual = append(actual, x)
+}
+
+func TestRandomEmptyRangePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Random(5, 5): expecting a panic")
+		}
+	}()
+	Random(rand.New(rand.NewSource(1)), 5, 5)
+}
+
+func TestPrimes(t *testing.T) {
+	size := 1000
+	var expected []int
+	composite := make([]bool, 8000)
+	for i := 2; i < len(composite) && len(expected) < size; i++ {
+		if composite[i] {
+			continue
+		}
+		expected = append(expected, i)
+		for j := i * i; j < len(composite); j += i {
+			composite[j] = true
+		}
+	}
+	itertest.AssertEqual(t, Primes().Take(size), expected)
+}
+
+func BenchmarkPrimesTrialDivision(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Seq().Drop(2).Filter(isPrimeTrialDivision).Take(10000).Collect()
+	}
+}
+
+func BenchmarkPrimesSieve(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Primes().Take(10000).Collect()
+	}
+}
+
+func isPrimeTrialDivision(n int) bool {
+	for i := 2; i*i <= n; i++ {
+		if n%i == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestArithmetic(t *testing.T) {
+	expected := []int{5, 2, -1, -4, -7}
+	itertest.AssertEqual(t, Arithmetic(5, -3).Take(5), expected)
+}
+
+func TestGeometric(t *testing.T) {
+	expected := []int{3, -6, 12, -24, 48}
+	itertest.AssertEqual(t, Geometric(3, -2).Take(5), expected)
+}
+
+func TestGeometricStopsOnOverflow(t *testing.T) {
+	var expected []int
+	for x := 1; x > 0; x *= 2 {
+		expected = append(expected, x)
+	}
+	actual := Geometric(1, 2).Collect()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Geometric(1, 2): expecting %v, got %v", expected, actual)
+	}
+	last := actual[len(actual)-1]
+	if last != 1<<62 {
+		t.Errorf("Geometric(1, 2): expecting the last element to be %d, got %d", 1<<62, last)
 	}
 
+	expected = []int{-1 << 62, math.MinInt}
+	actual = Geometric(-1<<62, 2).Collect()
 	if !reflect.DeepEqual(expected, actual) {
-		t.Errorf("Filter(isEven): expecting %v, got %v", expected, actual)
+		t.Errorf("Geometric(%d, 2): expecting %v, got %v", -1<<62, expected, actual)
 	}
 }
 
-func TestReduce(t *testing.T) {
-	size := 10
-	it := makeIter(size)
-	add := func(acc, cur int) int { return acc + cur }
-	var expected, actual int
-	for i := 0; i < size; i++ {
-		expected += i
+func TestSeqFrom(t *testing.T) {
+	tests := []struct {
+		start, step int
+		expected    []int
+	}{
+		{10, 1, []int{10, 11, 12, 13}},
+		{10, -5, []int{10, 5, 0, -5}},
+		{0, 1 << 40, []int{0, 1 << 40, 2 << 40, 3 << 40}},
 	}
-	actual = it.Reduce(0, add)
+	for _, test := range tests {
+		actual := SeqFrom(test.start, test.step).Take(len(test.expected)).Collect()
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf("SeqFrom(%d, %d): expecting %v, got %v", test.start, test.step, test.expected, actual)
+		}
+	}
+}
 
-	if expected != actual {
-		t.Errorf("Reduce(add): expecting %d, got %d", expected, actual)
+func TestSeqFromZeroStepPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("SeqFrom(0, 0): expecting a panic")
+		}
+	}()
+	SeqFrom(0, 0)
+}
+
+func TestDigits(t *testing.T) {
+	tests := []struct {
+		n        int
+		expected []int
+	}{
+		{0, []int{0}},
+		{7, []int{7}},
+		{1203, []int{1, 2, 0, 3}},
+		{-45, []int{4, 5}},
+		{math.MinInt64, []int{9, 2, 2, 3, 3, 7, 2, 0, 3, 6, 8, 5, 4, 7, 7, 5, 8, 0, 8}},
+	}
+	for _, test := range tests {
+		actual := Digits(test.n).Collect()
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf("Digits(%d): expecting %v, got %v", test.n, test.expected, actual)
+		}
 	}
 }
 
-func makeIter(max int) Iter {
-	it := make(chan int)
-	go func() {
-		defer close(it)
-		for i := 0; i < max; i++ {
-			it <- i
+func TestDigitsBase(t *testing.T) {
+	tests := []struct {
+		n, base  int
+		expected []int
+	}{
+		{0, 2, []int{0}},
+		{10, 2, []int{1, 0, 1, 0}},
+		{255, 16, []int{15, 15}},
+		{-8, 8, []int{1, 0}},
+	}
+	for _, test := range tests {
+		actual := DigitsBase(test.n, test.base).Collect()
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf("DigitsBase(%d, %d): expecting %v, got %v", test.n, test.base, test.expected, actual)
+		}
+	}
+}
+
+func TestDigitsBaseInvalidBasePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("DigitsBase(10, 1): expecting a panic")
 		}
 	}()
-	return it
+	DigitsBase(10, 1)
+}
+
+func TestBits(t *testing.T) {
+	tests := []struct {
+		n        uint64
+		expected []int
+	}{
+		{0, nil},
+		{0b10110, []int{1, 2, 4}},
+		{1 << 63, []int{63}},
+		{math.MaxUint64, Range(0, 64).Collect()},
+	}
+	for _, test := range tests {
+		actual := Bits(test.n).Collect()
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf("Bits(%b): expecting %v, got %v", test.n, test.expected, actual)
+		}
+		if n := ToBits(Bits(test.n)); n != test.n {
+			t.Errorf("ToBits(Bits(%b)): expecting %b, got %b", test.n, test.n, n)
+		}
+	}
+}
+
+func TestToBitsOutOfRangePanics(t *testing.T) {
+	for _, x := range []int{-1, 64} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("ToBits with position %d: expecting a panic", x)
+				}
+			}()
+			ToBits(RepeatN(x, 1))
+		}()
+	}
+}
+
+func TestTickCtx(t *testing.T) {
+	itertest.CheckNoLeaks(t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		itertest.AssertEqual(t, TickCtx(ctx, time.Millisecond).Take(5), []int{0, 1, 2, 3, 4})
+		cancel()
+	})
+}
+
+func TestTickCtxCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if actual := TickCtx(ctx, time.Hour).Collect(); actual != nil {
+		t.Errorf("TickCtx (cancelled): expecting an empty Iter, got %v", actual)
+	}
+}
+
+func TestFromSlices(t *testing.T) {
+	tests := []struct {
+		batches  [][]int
+		expected []int
+	}{
+		{nil, nil},
+		{[][]int{}, nil},
+		{[][]int{nil, {}}, nil},
+		{[][]int{{1, 2}, nil, {3}, {}, {4, 5, 6}}, []int{1, 2, 3, 4, 5, 6}},
+	}
+	for _, test := range tests {
+		actual := FromSlices(test.batches).Collect()
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf("FromSlices(%v): expecting %v, got %v", test.batches, test.expected, actual)
+		}
+	}
+}
+
+func TestFromSlicesRoundTrip(t *testing.T) {
+	expected := Range(0, 10).Collect()
+	var batches [][]int
+	for i := 0; i < len(expected); i += 3 {
+		end := i + 3
+		if end > len(expected) {
+			end = len(expected)
+		}
+		batches = append(batches, expected[i:end])
+	}
+	itertest.AssertEqual(t, FromSlices(batches), expected)
+}
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		it       Iter[int]
+		expected int
+	}{
+		{itertest.FromSlice(ints(0)), 0},
+		{Range(0, 1000), 1000},
+	}
+	for _, test := range tests {
+		if actual := test.it.Count(); actual != test.expected {
+			t.Errorf("Count(): expecting %d, got %d", test.expected, actual)
+		}
+	}
+}
+
+func BenchmarkCount(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Range(0, 1000).Count()
+	}
+}
+
+func BenchmarkCountByCollect(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = len(Range(0, 1000).Collect())
+	}
+}
+
+func TestSumAndProduct(t *testing.T) {
+	tests := []struct {
+		s                          []int
+		sum, product               int
+		checkedSum, checkedProduct int
+		sumOk, productOk           bool
+	}{
+		{nil, 0, 1, 0, 1, true, true},
+		{[]int{1, 2, 3, 4}, 10, 24, 10, 24, true, true},
+		{[]int{-2, 3, -4}, -3, 24, -3, 24, true, true},
+		{[]int{math.MaxInt, math.MaxInt}, -2, 1, 0, 0, false, false},
+		{[]int{math.MinInt, -1}, math.MaxInt, math.MinInt, 0, 0, false, false},
+	}
+	for _, test := range tests {
+		if actual := Sum(itertest.FromSlice(test.s)); actual != test.sum {
+			t.Errorf("Sum() of %v: expecting %d, got %d", test.s, test.sum, actual)
+		}
+		if actual := Product(itertest.FromSlice(test.s)); actual != test.product {
+			t.Errorf("Product() of %v: expecting %d, got %d", test.s, test.product, actual)
+		}
+		if actual, ok := SumChecked(itertest.FromSlice(test.s)); actual != test.checkedSum || ok != test.sumOk {
+			t.Errorf("SumChecked() of %v: expecting (%d, %t), got (%d, %t)", test.s, test.checkedSum, test.sumOk, actual, ok)
+		}
+		if actual, ok := ProductChecked(itertest.FromSlice(test.s)); actual != test.checkedProduct || ok != test.productOk {
+			t.Errorf("ProductChecked() of %v: expecting (%d, %t), got (%d, %t)", test.s, test.checkedProduct, test.productOk, actual, ok)
+		}
+	}
+}
+
+func TestMinMax(t *testing.T) {
+	tests := []struct {
+		s        []int
+		min, max int
+		ok       bool
+	}{
+		{nil, 0, 0, false},
+		{[]int{5}, 5, 5, true},
+		{[]int{3, 3, 3}, 3, 3, true},
+		{[]int{2, -7, 9, 0}, -7, 9, true},
+		{[]int{0, math.MaxInt, math.MinInt}, math.MinInt, math.MaxInt, true},
+	}
+	for _, test := range tests {
+		if min, max, ok := MinMax(itertest.FromSlice(test.s)); min != test.min || max != test.max || ok != test.ok {
+			t.Errorf("MinMax() of %v: expecting (%d, %d, %t), got (%d, %d, %t)", test.s, test.min, test.max, test.ok, min, max, ok)
+		}
+		if min, ok := Min(itertest.FromSlice(test.s)); min != test.min || ok != test.ok {
+			t.Errorf("Min() of %v: expecting (%d, %t), got (%d, %t)", test.s, test.min, test.ok, min, ok)
+		}
+		if max, ok := Max(itertest.FromSlice(test.s)); max != test.max || ok != test.ok {
+			t.Errorf("Max() of %v: expecting (%d, %t), got (%d, %t)", test.s, test.max, test.ok, max, ok)
+		}
+	}
+}
+
+func TestMinByMaxBy(t *testing.T) {
+	distance := func(x int) int {
+		if x > 100 {
+			return x - 100
+		}
+		return 100 - x
+	}
+	tests := []struct {
+		s        []int
+		min, max int
+		ok       bool
+	}{
+		{nil, 0, 0, false},
+		{[]int{42}, 42, 42, true},
+		{[]int{3, 97, 150, 104, 250}, 97, 250, true},
+		// ties are broken by the first element
+		{[]int{90, 110, 0, 200}, 90, 0, true},
+	}
+	for _, test := range tests {
+		if min, ok := MinBy(itertest.FromSlice(test.s), distance); min != test.min || ok != test.ok {
+			t.Errorf("MinBy(|x-100|) of %v: expecting (%d, %t), got (%d, %t)", test.s, test.min, test.ok, min, ok)
+		}
+		if max, ok := MaxBy(itertest.FromSlice(test.s), distance); max != test.max || ok != test.ok {
+			t.Errorf("MaxBy(|x-100|) of %v: expecting (%d, %t), got (%d, %t)", test.s, test.max, test.ok, max, ok)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	tests := []struct {
+		s        []int
+		expected Summary
+	}{
+		{nil, Summary{}},
+		{[]int{7, 7, 7, 7}, Summary{Count: 4, Min: 7, Max: 7, Sum: 28, Mean: 7}},
+		{[]int{2, 4, 4, 4, 5, 5, 7, 9}, Summary{Count: 8, Min: 2, Max: 9, Sum: 40, Mean: 5, Variance: 4, StdDev: 2}},
+		{[]int{-3, 1, -1, 3}, Summary{Count: 4, Min: -3, Max: 3, Sum: 0, Mean: 0, Variance: 5, StdDev: math.Sqrt(5)}},
+	}
+	for _, test := range tests {
+		actual := Stats(itertest.FromSlice(test.s))
+		e := test.expected
+		if actual.Count != e.Count || actual.Min != e.Min || actual.Max != e.Max || actual.Sum != e.Sum ||
+			!approxEqual(actual.Mean, e.Mean) || !approxEqual(actual.Variance, e.Variance) || !approxEqual(actual.StdDev, e.StdDev) {
+			t.Errorf("Stats() of %v: expecting %+v, got %+v", test.s, e, actual)
+		}
+	}
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestAnyAllNone(t *testing.T) {
+	isEven := func(x int) bool { return x%2 == 0 }
+	tests := []struct {
+		s              []int
+		any, all, none bool
+	}{
+		{nil, false, true, true},
+		{[]int{2, 4, 6}, true, true, false},
+		{[]int{1, 3, 4}, true, false, false},
+		{[]int{1, 3, 5}, false, false, true},
+	}
+	for _, test := range tests {
+		if actual := itertest.FromSlice(test.s).Any(isEven); actual != test.any {
+			t.Errorf("Any(isEven) of %v: expecting %t, got %t", test.s, test.any, actual)
+		}
+		if actual := itertest.FromSlice(test.s).All(isEven); actual != test.all {
+			t.Errorf("All(isEven) of %v: expecting %t, got %t", test.s, test.all, actual)
+		}
+		if actual := itertest.FromSlice(test.s).None(isEven); actual != test.none {
+			t.Errorf("None(isEven) of %v: expecting %t, got %t", test.s, test.none, actual)
+		}
+	}
+}
+
+func TestAnyAllNoneInfinite(t *testing.T) {
+	greaterThan10 := func(x int) bool { return x > 10 }
+	if !Seq().Any(greaterThan10) {
+		t.Errorf("Seq().Any(x > 10): expecting true")
+	}
+	if Seq().All(func(x int) bool { return x < 10 }) {
+		t.Errorf("Seq().All(x < 10): expecting false")
+	}
+	if Seq().None(greaterThan10) {
+		t.Errorf("Seq().None(x > 10): expecting false")
+	}
+}
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		it       Iter[int]
+		pred     func(int) bool
+		expected int
+		ok       bool
+	}{
+		{itertest.FromSlice(ints(10)), func(x int) bool { return x == 0 }, 0, true},
+		{Seq(), func(x int) bool { return x*x > 1000000 }, 1001, true},
+		{itertest.FromSlice(ints(10)), func(x int) bool { return x > 100 }, 0, false},
+		{itertest.FromSlice(ints(0)), func(x int) bool { return true }, 0, false},
+	}
+	for _, test := range tests {
+		if actual, ok := test.it.Find(test.pred); actual != test.expected || ok != test.ok {
+			t.Errorf("Find(): expecting (%d, %t), got (%d, %t)", test.expected, test.ok, actual, ok)
+		}
+	}
+}
+
+func TestPosition(t *testing.T) {
+	sum := 0
+	runningSumExceeds := func(n int) func(int) bool {
+		return func(x int) bool {
+			sum += x
+			return sum > n
+		}
+	}
+	tests := []struct {
+		it       Iter[int]
+		pred     func(int) bool
+		expected int
+		ok       bool
+	}{
+		{itertest.FromSlice(ints(10)), func(x int) bool { return x == 0 }, 0, true},
+		{Seq(), runningSumExceeds(1000000), 1414, true},
+		{itertest.FromSlice(ints(10)), func(x int) bool { return x > 100 }, 0, false},
+	}
+	for _, test := range tests {
+		if actual, ok := test.it.Position(test.pred); actual != test.expected || ok != test.ok {
+			t.Errorf("Position(): expecting (%d, %t), got (%d, %t)", test.expected, test.ok, actual, ok)
+		}
+	}
+}
+
+func TestFirstLast(t *testing.T) {
+	tests := []struct {
+		size        int
+		first, last int
+		ok          bool
+	}{
+		{0, 0, 0, false},
+		{1, 0, 0, true},
+		{100000, 0, 99999, true},
+	}
+	for _, test := range tests {
+		if first, ok := itertest.FromSlice(ints(test.size)).First(); first != test.first || ok != test.ok {
+			t.Errorf("First(), size = %d: expecting (%d, %t), got (%d, %t)", test.size, test.first, test.ok, first, ok)
+		}
+		if last, ok := itertest.FromSlice(ints(test.size)).Last(); last != test.last || ok != test.ok {
+			t.Errorf("Last(), size = %d: expecting (%d, %t), got (%d, %t)", test.size, test.last, test.ok, last, ok)
+		}
+	}
+}
+
+func TestFirstInfinite(t *testing.T) {
+	if first, ok := Seq().Drop(5).First(); first != 5 || !ok {
+		t.Errorf("Seq().Drop(5).First(): expecting (5, true), got (%d, %t)", first, ok)
+	}
+}
+
+func TestNth(t *testing.T) {
+	size := 10
+	tests := []struct {
+		n, expected int
+		ok          bool
+	}{
+		{0, 0, true},
+		{size - 1, size - 1, true},
+		{size, 0, false},
+		{size + 5, 0, false},
+	}
+	for _, test := range tests {
+		if actual, ok := itertest.FromSlice(ints(size)).Nth(test.n); actual != test.expected || ok != test.ok {
+			t.Errorf("Nth(%d), size = %d: expecting (%d, %t), got (%d, %t)", test.n, size, test.expected, test.ok, actual, ok)
+		}
+	}
+}
+
+func TestNthInfinite(t *testing.T) {
+	square := func(x int) int { return x * x }
+	if actual, ok := Seq().Map(square).Nth(1000); actual != 1000000 || !ok {
+		t.Errorf("Seq().Map(square).Nth(1000): expecting (1000000, true), got (%d, %t)", actual, ok)
+	}
+}
+
+func TestNthNegativePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Nth(-1): expecting a panic")
+		}
+	}()
+	itertest.FromSlice(ints(10)).Nth(-1)
+}
+
+func TestForEach(t *testing.T) {
+	size := 100
+	expected := ints(size)
+	var actual []int
+	itertest.FromSlice(ints(size)).ForEach(func(x int) { actual = append(actual, x) })
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("ForEach(): expecting %v, got %v", expected, actual)
+	}
+}
+
+func TestForEachIndexed(t *testing.T) {
+	expected := []int{0, 1, 2, 3, 4}
+	var actual []int
+	Range(10, 15).ForEachIndexed(func(i, x int) {
+		if x != i+10 {
+			t.Errorf("ForEachIndexed(): expecting element %d at index %d, got %d", i+10, i, x)
+		}
+		actual = append(actual, i)
+	})
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("ForEachIndexed(): expecting indices %v, got %v", expected, actual)
+	}
+}
+
+func TestForEachParallel(t *testing.T) {
+	size, workers := 1000, 8
+	var mu sync.Mutex
+	var actual []int
+	Range(0, size).ForEachParallel(workers, func(x int) {
+		time.Sleep(time.Microsecond)
+		mu.Lock()
+		actual = append(actual, x)
+		mu.Unlock()
+	})
+	sort.Ints(actual)
+	expected := Range(0, size).Collect()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("ForEachParallel(%d): expecting every element of %v, got %v", workers, expected, actual)
+	}
+}
+
+func TestForEachParallelPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		it   func() Iter[int]
+	}{
+		{"Range(0, 100)", func() Iter[int] { return Range(0, 100) }},
+		{"Seq()", Seq},
+	}
+	for _, test := range tests {
+		returned := make(chan interface{})
+		go func() {
+			defer func() { returned <- recover() }()
+			test.it().ForEachParallel(4, func(x int) {
+				if x == 50 {
+					panic("boom")
+				}
+			})
+		}()
+		select {
+		case r := <-returned:
+			if r != "boom" {
+				t.Errorf("ForEachParallel of %s: expecting the panic %q to be re-raised, got %v", test.name, "boom", r)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("ForEachParallel of %s: expecting to return after fn panics", test.name)
+		}
+	}
+}
+
+func TestForEachParallelErr(t *testing.T) {
+	errs := Range(0, 100).ForEachParallelErr(0, func(x int) error {
+		if x%10 == 0 {
+			return fmt.Errorf("bad element %d", x)
+		}
+		return nil
+	})
+	if len(errs) != 10 {
+		t.Errorf("ForEachParallelErr: expecting 10 errors, got %v", errs)
+	}
+}
+
+func TestCollectInto(t *testing.T) {
+	buf := make([]int, 2, 10)
+	buf[0], buf[1] = -1, -2
+	expected := []int{-1, -2, 0, 1, 2}
+	actual := itertest.FromSlice(ints(3)).CollectInto(buf)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("CollectInto(%v): expecting %v, got %v", buf, expected, actual)
+	}
+	if &actual[0] != &buf[0] {
+		t.Errorf("CollectInto(): expecting the capacity of buf to be reused")
+	}
+	if actual := itertest.FromSlice(ints(0)).CollectInto(nil); actual != nil {
+		t.Errorf("CollectInto(nil) of an empty Iter: expecting nil, got %v", actual)
+	}
+}
+
+func TestCollectCap(t *testing.T) {
+	size := 100
+	expected := ints(size)
+	actual := itertest.FromSlice(ints(size)).CollectCap(size)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("CollectCap(%d): expecting %v, got %v", size, expected, actual)
+	}
+	if cap(actual) != size {
+		t.Errorf("CollectCap(%d): expecting capacity %d, got %d", size, size, cap(actual))
+	}
+}
+
+func BenchmarkCollect(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Range(0, 1000).Collect()
+	}
+}
+
+func BenchmarkCollectInto(b *testing.B) {
+	b.ReportAllocs()
+	buf := make([]int, 0, 1000)
+	for i := 0; i < b.N; i++ {
+		buf = Range(0, 1000).CollectInto(buf[:0])
+	}
+}
+
+func TestCollectN(t *testing.T) {
+	n := 10
+	tests := []struct {
+		size     int
+		expected []int
+	}{
+		{5, ints(5)},
+		{n, ints(n)},
+		{20, ints(n)},
+	}
+	for _, test := range tests {
+		actual := itertest.FromSlice(ints(test.size)).CollectN(n)
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf("CollectN(%d), size = %d: expecting %v, got %v", n, test.size, test.expected, actual)
+		}
+	}
+}
+
+func TestCollectNReadsAtMostN(t *testing.T) {
+	it := itertest.FromSlice(ints(10))
+	it.CollectN(3)
+	if x, ok := <-it; x != 3 || !ok {
+		t.Errorf("CollectN(3): expecting the next element to be 3, got (%d, %t)", x, ok)
+	}
+}
+
+func BenchmarkTakeCollect(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Seq().Take(1000).Collect()
+	}
+}
+
+func BenchmarkCollectN(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Seq().CollectN(1000)
+	}
+}
+
+func TestCollectMax(t *testing.T) {
+	max := 10
+	tests := []struct {
+		it       Iter[int]
+		expected []int
+		err      error
+	}{
+		{itertest.FromSlice(ints(5)), ints(5), nil},
+		{itertest.FromSlice(ints(max)), ints(max), nil},
+		{itertest.FromSlice(ints(max + 1)), ints(max), ErrTooManyElements},
+		{Seq(), ints(max), ErrTooManyElements},
+	}
+	for _, test := range tests {
+		actual, err := test.it.CollectMax(max)
+		if !reflect.DeepEqual(test.expected, actual) || !errors.Is(err, test.err) {
+			t.Errorf("CollectMax(%d): expecting (%v, %v), got (%v, %v)", max, test.expected, test.err, actual, err)
+		}
+	}
+}
+
+func TestCollectMaxConsumesOneExtra(t *testing.T) {
+	max := 10
+	ch := make(chan int, 2*max)
+	for _, x := range ints(2 * max) {
+		ch <- x
+	}
+	close(ch)
+	if _, err := Iter[int](ch).CollectMax(max); !errors.Is(err, ErrTooManyElements) {
+		t.Errorf("CollectMax(%d): expecting %v, got %v", max, ErrTooManyElements, err)
+	}
+	if x, ok := <-ch; x != max+1 || !ok {
+		t.Errorf("CollectMax(%d): expecting the next element to be %d, got (%d, %t)", max, max+1, x, ok)
+	}
+}
+
+func TestCollectMaxNegativePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("CollectMax(-1): expecting a panic")
+		}
+	}()
+	itertest.FromSlice(ints(10)).CollectMax(-1)
+}
+
+func TestFrequencies(t *testing.T) {
+	tests := []struct {
+		s        []int
+		expected map[int]int
+	}{
+		{nil, map[int]int{}},
+		{[]int{3, 1, 3, -2, 3, 1}, map[int]int{3: 3, 1: 2, -2: 1}},
+	}
+	for _, test := range tests {
+		actual := Frequencies(itertest.FromSlice(test.s))
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf("Frequencies() of %v: expecting %v, got %v", test.s, test.expected, actual)
+		}
+	}
+}
+
+func TestCountBy(t *testing.T) {
+	expected := make(map[int]int)
+	for decade := 0; decade < 10; decade++ {
+		expected[decade] = 10
+	}
+	actual := CountBy(Range(0, 100), func(x int) int { return x / 10 })
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("CountBy(x / 10): expecting %v, got %v", expected, actual)
+	}
+}
+
+func TestJoinString(t *testing.T) {
+	tests := []struct {
+		s        []int
+		expected string
+	}{
+		{nil, ""},
+		{[]int{42}, "42"},
+		{[]int{1, -2, 3}, "1, -2, 3"},
+	}
+	for _, test := range tests {
+		if actual := itertest.FromSlice(test.s).JoinString(", "); actual != test.expected {
+			t.Errorf("JoinString(\", \") of %v: expecting %q, got %q", test.s, test.expected, actual)
+		}
+	}
+}
+
+func TestJoinStringFunc(t *testing.T) {
+	expected := "0x0a|0xff"
+	actual := itertest.FromSlice([]int{10, 255}).JoinStringFunc("|", func(x int) string { return fmt.Sprintf("0x%02x", x) })
+	if actual != expected {
+		t.Errorf("JoinStringFunc(\"|\", hex): expecting %q, got %q", expected, actual)
+	}
+}
+
+func TestJoinStringFormatsLikeSprint(t *testing.T) {
+	tests := []struct {
+		name     string
+		actual   string
+		expected string
+	}{
+		{"int8", FromSlices([][]int8{{-128, 127}}).JoinString(" "), fmt.Sprint(int8(-128), " ", int8(127))},
+		{"uint64", FromSlices([][]uint64{{0, math.MaxUint64}}).JoinString(" "), fmt.Sprint(uint64(0), " ", uint64(math.MaxUint64))},
+		{"float32", FromSlices([][]float32{{0.1, 1e20}}).JoinString(" "), fmt.Sprint(float32(0.1), " ", float32(1e20))},
+		{"float64", FromSlices([][]float64{{0.1, 1e21, math.Inf(-1), math.NaN()}}).JoinString(" "), "0.1 1e+21 -Inf NaN"},
+		{"string", FromSlices([][]string{{"a", "", "b c"}}).JoinString("|"), "a||b c"},
+		{"bool", FromSlices([][]bool{{true, false}}).JoinString(" "), "true false"},
+		{"time.Duration", FromSlices([][]time.Duration{{time.Second}}).JoinString(" "), "1s"},
+	}
+	for _, test := range tests {
+		if test.actual != test.expected {
+			t.Errorf("JoinString() of %s: expecting %q, got %q", test.name, test.expected, test.actual)
+		}
+	}
+}
+
+func TestJoinStringAllocs(t *testing.T) {
+	size := 10000
+	joined := testing.AllocsPerRun(3, func() { Range(0, size).JoinString(" ") })
+	sprinted := testing.AllocsPerRun(3, func() { _ = fmt.Sprint(Range(0, size).Collect()) })
+	if joined > sprinted/10 {
+		t.Errorf("JoinString() of %d elements: expecting far fewer allocations than Sprint(Collect()) (%v), got %v", size, sprinted, joined)
+	}
+}
+
+func BenchmarkJoinString(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Range(0, 100000).JoinString(" ")
+	}
+}
+
+func BenchmarkSprintCollect(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = fmt.Sprint(Range(0, 100000).Collect())
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := itertest.FromSlice([]int{1, -20, 300}).WriteTo(&buf)
+	expected := "1\n-20\n300\n"
+	if buf.String() != expected || n != int64(len(expected)) || err != nil {
+		t.Errorf("WriteTo(): expecting (%q, %d, nil), got (%q, %d, %v)", expected, len(expected), buf.String(), n, err)
+	}
+}
+
+func TestWriteToSep(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := itertest.FromSlice(ints(3)).WriteToSep(&buf, ",")
+	expected := "0,1,2,"
+	if buf.String() != expected || n != int64(len(expected)) || err != nil {
+		t.Errorf("WriteToSep(\",\"): expecting (%q, %d, nil), got (%q, %d, %v)", expected, len(expected), buf.String(), n, err)
+	}
+}
+
+func TestWriteToStopsAtError(t *testing.T) {
+	w := &failingWriter{limit: 10000}
+	it := Range(0, 1000000)
+	n, err := it.WriteTo(w)
+	if err != errWriteFailed {
+		t.Errorf("WriteTo(failing writer): expecting error %v, got %v", errWriteFailed, err)
+	}
+	if n != int64(w.limit) {
+		t.Errorf("WriteTo(failing writer): expecting %d bytes written, got %d", w.limit, n)
+	}
+	// the Iter is closed at the error, though an element already being sent may still arrive
+	if rest := it.Count(); rest > 1 {
+		t.Errorf("WriteTo(failing writer): expecting the Iter to be closed, got %d more elements", rest)
+	}
+}
+
+func BenchmarkWriteTo(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Range(0, 1000000).WriteTo(io.Discard)
+	}
+}
+
+var errWriteFailed = errors.New("write failed")
+
+// failingWriter accepts limit bytes and then fails every write.
+type failingWriter struct {
+	limit, written int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.written+len(p) > w.limit {
+		n := w.limit - w.written
+		w.written = w.limit
+		return n, errWriteFailed
+	}
+	w.written += len(p)
+	return len(p), nil
+}
+
+func TestWriteBinary(t *testing.T) {
+	s := []int{0, 1, -1, 300, math.MaxInt64, math.MinInt64}
+	var buf bytes.Buffer
+	n, err := WriteBinary(itertest.FromSlice(s), &buf)
+	expected := encodeVarints(s)
+	if !bytes.Equal(expected, buf.Bytes()) || n != int64(len(expected)) || err != nil {
+		t.Errorf("WriteBinary() of %v: expecting (%v, %d, nil), got (%v, %d, %v)", s, expected, len(expected), buf.Bytes(), n, err)
+	}
+}
+
+func TestWriteBinaryRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var expected []int
+	for i := 0; i < 3000; i++ {
+		switch i % 3 {
+		case 0:
+			expected = append(expected, rng.Intn(2000)-1000)
+		case 1:
+			expected = append(expected, math.MaxInt64-rng.Intn(1000))
+		case 2:
+			expected = append(expected, math.MinInt64+rng.Intn(1000))
+		}
+	}
+	expected = append(expected, 0)
+	var buf bytes.Buffer
+	if _, err := WriteBinary(itertest.FromSlice(expected), &buf); err != nil {
+		t.Fatalf("WriteBinary(): unexpected error %v", err)
+	}
+	itertest.AssertEqual(t, FromBinary(&buf), expected)
+}
+
+func TestWriteBinaryStopsAtError(t *testing.T) {
+	w := &failingWriter{limit: 10000}
+	n, err := WriteBinary(Range(0, 1000000), w)
+	if err != errWriteFailed || n != int64(w.limit) {
+		t.Errorf("WriteBinary(failing writer): expecting (%d, %v), got (%d, %v)", w.limit, errWriteFailed, n, err)
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	for _, size := range []int{0, 1, 1000} {
+		var buf bytes.Buffer
+		if err := itertest.FromSlice(ints(size)).EncodeJSON(&buf); err != nil {
+			t.Fatalf("EncodeJSON(), size = %d: unexpected error %v", size, err)
+		}
+		expected := ints(size)
+		var actual []int
+		if err := json.Unmarshal(buf.Bytes(), &actual); err != nil {
+			t.Fatalf("EncodeJSON(), size = %d: invalid JSON %q: %v", size, buf.String(), err)
+		}
+		if len(expected) != len(actual) || (size > 0 && !reflect.DeepEqual(expected, actual)) {
+			t.Errorf("EncodeJSON(), size = %d: expecting %v, got %v", size, expected, actual)
+		}
+	}
+}
+
+func TestEncodeJSONIndent(t *testing.T) {
+	for _, size := range []int{0, 1, 3} {
+		var buf bytes.Buffer
+		if err := itertest.FromSlice(ints(size)).EncodeJSONIndent(&buf, ">", "  "); err != nil {
+			t.Fatalf("EncodeJSONIndent(), size = %d: unexpected error %v", size, err)
+		}
+		expected, _ := json.MarshalIndent(itertest.FromSlice(ints(size)).CollectCap(0), ">", "  ")
+		if buf.String() != string(expected) {
+			t.Errorf("EncodeJSONIndent(), size = %d: expecting %q, got %q", size, expected, buf.String())
+		}
+	}
+}
+
+func TestEncodeJSONStopsAtError(t *testing.T) {
+	w := &failingWriter{limit: 10000}
+	it := Range(0, 1000000)
+	if err := it.EncodeJSON(w); err != errWriteFailed {
+		t.Errorf("EncodeJSON(failing writer): expecting error %v, got %v", errWriteFailed, err)
+	}
+	// the Iter is closed at the error, though an element already being sent may still arrive
+	if rest := it.Count(); rest > 1 {
+		t.Errorf("EncodeJSON(failing writer): expecting the Iter to be closed, got %d more elements", rest)
+	}
+}
+
+func TestContains(t *testing.T) {
+	square := func(x int) int { return x * x }
+	tests := []struct {
+		it       Iter[int]
+		x        int
+		expected bool
+	}{
+		{itertest.FromSlice(ints(10)), 0, true},
+		{Seq().Map(square), 144, true},
+		{itertest.FromSlice(ints(10)), 10, false},
+		{itertest.FromSlice(ints(0)), 0, false},
+	}
+	for _, test := range tests {
+		if actual := Contains(test.it, test.x); actual != test.expected {
+			t.Errorf("Contains(%d): expecting %t, got %t", test.x, test.expected, actual)
+		}
+	}
+}
+
+func TestIsSorted(t *testing.T) {
+	tests := []struct {
+		s        []int
+		expected bool
+	}{
+		{nil, true},
+		{[]int{1}, true},
+		{[]int{1, 2, 3}, true},
+		{[]int{1, 1, 2, 2}, true},
+		{[]int{2, 1, 3, 4}, false},
+		{[]int{1, 2, 3, 2}, false},
+	}
+	for _, test := range tests {
+		if actual := IsSorted(itertest.FromSlice(test.s)); actual != test.expected {
+			t.Errorf("IsSorted() of %v: expecting %t, got %t", test.s, test.expected, actual)
+		}
+	}
+}
+
+func TestIsSortedBy(t *testing.T) {
+	greater := func(a, b int) bool { return a > b }
+	if !itertest.FromSlice([]int{3, 2, 2, 1}).IsSortedBy(greater) {
+		t.Errorf("IsSortedBy(greater) of [3 2 2 1]: expecting true")
+	}
+	if itertest.FromSlice([]int{3, 1, 2}).IsSortedBy(greater) {
+		t.Errorf("IsSortedBy(greater) of [3 1 2]: expecting false")
+	}
+}
+
+func TestSortedWithin(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	expected := Range(0, 1000).Collect()
+	for _, k := range []int{1, 3, 10} {
+		// shuffling within windows of k+1 moves every element at most k positions away
+		shuffled := slices.Clone(expected)
+		for i := 0; i < len(shuffled); i += k + 1 {
+			w := shuffled[i:min(i+k+1, len(shuffled))]
+			rng.Shuffle(len(w), func(i, j int) { w[i], w[j] = w[j], w[i] })
+		}
+		violations := 0
+		actual := itertest.FromSlice(shuffled).SortedWithinBy(k, func(a, b int) bool { return a < b }, func(int, int) { violations++ }).Collect()
+		if !reflect.DeepEqual(expected, actual) || violations != 0 {
+			t.Errorf("SortedWithinBy(%d) of a stream shuffled within windows of %d: expecting it sorted without violations, got %d violations", k, k+1, violations)
+		}
+	}
+
+	itertest.AssertEqual(t, SortedWithin(itertest.FromSlice([]int{3, 1, 2}), 0), []int{3, 1, 2})
+	itertest.AssertEqual(t, SortedWithin(itertest.FromSlice([]int{3, 1, 2}), 5), []int{1, 2, 3})
+	itertest.AssertEqual(t, SortedWithin(FromSlices[int](nil), 2), nil)
+	itertest.AssertEqual(t, SortedWithin(itertest.FromSlice([]int{3, 1, 2}), 1<<40), []int{1, 2, 3})
+	itertest.AssertEqual(t, SortedWithin(itertest.FromSlice([]int{3, 1, 2}), math.MaxInt), []int{1, 2, 3})
+	if actual := FromSlices([][]struct{}{{{}, {}}}).SortedWithinBy(math.MaxInt, func(a, b struct{}) bool { return false }, nil).Count(); actual != 2 {
+		t.Errorf("SortedWithinBy(math.MaxInt) of 2 empty structs: expecting 2 elements, got %d", actual)
+	}
+
+	// swapping every pair of neighbours moves every element one position away
+	swapped := Seq().Map(func(x int) int { return x ^ 1 })
+	itertest.AssertEqual(t, SortedWithin(swapped, 1).Take(10), Range(0, 10).Collect())
+}
+
+func TestSortedWithinViolation(t *testing.T) {
+	var violations [][2]int
+	actual := itertest.FromSlice([]int{5, 1, 2, 3, 0}).SortedWithinBy(1, func(a, b int) bool { return a < b }, func(prev, x int) {
+		violations = append(violations, [2]int{prev, x})
+	}).Collect()
+	if expected := []int{1, 2, 3, 0, 5}; !reflect.DeepEqual(expected, actual) {
+		t.Errorf("SortedWithinBy(1) of [5 1 2 3 0]: expecting %v, got %v", expected, actual)
+	}
+	if expected := [][2]int{{3, 0}}; !reflect.DeepEqual(expected, violations) {
+		t.Errorf("SortedWithinBy(1) of [5 1 2 3 0]: expecting violations %v, got %v", expected, violations)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("SortedWithin(-1): expecting a panic")
+		}
+	}()
+	SortedWithin(FromSlices[int](nil), -1)
+}
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		a, b     []int
+		expected bool
+	}{
+		{nil, nil, true},
+		{[]int{1, 2, 3}, []int{1, 2, 3}, true},
+		{[]int{1, 2}, []int{1, 2, 3}, false},
+		{[]int{1, 2, 3}, []int{1, 2}, false},
+		{[]int{0, 2, 3}, []int{1, 2, 3}, false},
+	}
+	for _, test := range tests {
+		if actual := Equal(itertest.FromSlice(test.a), itertest.FromSlice(test.b)); actual != test.expected {
+			t.Errorf("Equal(%v, %v): expecting %t, got %t", test.a, test.b, test.expected, actual)
+		}
+	}
+}
+
+func TestEqualInfinite(t *testing.T) {
+	if Equal(Seq(), Seq().Map(func(x int) int { return x + 1 })) {
+		t.Errorf("Equal(Seq(), Seq().Map(x + 1)): expecting false")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b     []int
+		expected int
+	}{
+		{nil, nil, 0},
+		{[]int{1, 2, 3}, []int{1, 2, 3}, 0},
+		{[]int{1, 2, 3}, []int{1, 3}, -1},
+		{[]int{2}, []int{1, 5, 6}, +1},
+		{[]int{1, 2}, []int{1, 2, 3}, -1},
+		{[]int{1, 2, 3}, []int{1, 2}, +1},
+		{nil, []int{1}, -1},
+	}
+	for _, test := range tests {
+		if actual := Compare(itertest.FromSlice(test.a), itertest.FromSlice(test.b)); actual != test.expected {
+			t.Errorf("Compare(%v, %v): expecting %d, got %d", test.a, test.b, test.expected, actual)
+		}
+	}
+}
+
+func TestCompareInfinite(t *testing.T) {
+	if actual := Compare(Seq(), Seq().Map(func(x int) int { return x * 2 })); actual != -1 {
+		t.Errorf("Compare(Seq(), Seq().Map(x * 2)): expecting -1, got %d", actual)
+	}
+}
+
+func TestReduceWhile(t *testing.T) {
+	sumUntil := func(limit int) func(acc, cur int) (int, bool) {
+		return func(acc, cur int) (int, bool) {
+			acc += cur
+			return acc, acc <= limit
+		}
+	}
+	tests := []struct {
+		it       Iter[int]
+		limit    int
+		expected int
+	}{
+		// 0 + 1 + ... + 45 = 1035 is the first sum exceeding 1000
+		{Seq(), 1000, 1035},
+		{itertest.FromSlice(ints(10)), math.MaxInt, Sum(itertest.FromSlice(ints(10)))},
+		{itertest.FromSlice(ints(0)), 0, 0},
+	}
+	for _, test := range tests {
+		if actual := test.it.ReduceWhile(0, sumUntil(test.limit)); actual != test.expected {
+			t.Errorf("ReduceWhile(sum <= %d): expecting %d, got %d", test.limit, test.expected, actual)
+		}
+	}
+}
+
+func TestTryReduce(t *testing.T) {
+	errBad := errors.New("bad element")
+	tests := []struct {
+		bad      int
+		expected int
+		err      error
+	}{
+		{0, 0, errBad},
+		{5, 0 + 1 + 2 + 3 + 4, errBad},
+		{-1, Sum(itertest.FromSlice(ints(10))), nil},
+	}
+	for _, test := range tests {
+		var seen []int
+		actual, err := itertest.FromSlice(ints(10)).TryReduce(0, func(acc, cur int) (int, error) {
+			seen = append(seen, cur)
+			if cur == test.bad {
+				return 0, errBad
+			}
+			return acc + cur, nil
+		})
+		if actual != test.expected || err != test.err {
+			t.Errorf("TryReduce(failing at %d): expecting (%d, %v), got (%d, %v)", test.bad, test.expected, test.err, actual, err)
+		}
+		if test.err != nil && seen[len(seen)-1] != test.bad {
+			t.Errorf("TryReduce(failing at %d): expecting no elements after the failing one, got %v", test.bad, seen)
+		}
+	}
+}
+
+func TestTryForEach(t *testing.T) {
+	errBad := errors.New("bad element")
+	var seen []int
+	err := itertest.FromSlice(ints(10)).TryForEach(func(x int) error {
+		seen = append(seen, x)
+		if x == 3 {
+			return errBad
+		}
+		return nil
+	})
+	if expected := []int{0, 1, 2, 3}; err != errBad || !reflect.DeepEqual(expected, seen) {
+		t.Errorf("TryForEach(failing at 3): expecting (%v, %v), got (%v, %v)", expected, errBad, seen, err)
+	}
+	if err := itertest.FromSlice(ints(10)).TryForEach(func(int) error { return nil }); err != nil {
+		t.Errorf("TryForEach(never failing): expecting nil, got %v", err)
+	}
+}
+
+func TestGroupByToMap(t *testing.T) {
+	expected := map[int][]int{
+		0: {0, 3, 6, 9, 12, 15, 18},
+		1: {1, 4, 7, 10, 13, 16, 19},
+		2: {2, 5, 8, 11, 14, 17},
+	}
+	actual := GroupByToMap(Range(0, 20), func(x int) int { return x % 3 })
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("GroupByToMap(x %% 3): expecting %v, got %v", expected, actual)
+	}
+	if actual := GroupByToMap(itertest.FromSlice(ints(0)), func(x int) int { return x }); actual == nil || len(actual) != 0 {
+		t.Errorf("GroupByToMap() of an empty Iter: expecting an empty map, got %v", actual)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	it := itertest.FromSlice(ints(100))
+	it.Drain()
+	if x, ok := <-it; ok {
+		t.Errorf("Drain(): expecting the Iter to be closed, got element %d", x)
+	}
+}
+
+func TestDrainN(t *testing.T) {
+	tests := []struct {
+		it          Iter[int]
+		n, expected int
+	}{
+		{itertest.FromSlice(ints(10)), 5, 5},
+		{itertest.FromSlice(ints(10)), 20, 10},
+		{itertest.FromSlice(ints(10)), 0, 0},
+		{Seq(), 1000, 1000},
+	}
+	for _, test := range tests {
+		if actual := test.it.DrainN(test.n); actual != test.expected {
+			t.Errorf("DrainN(%d): expecting %d, got %d", test.n, test.expected, actual)
+		}
+	}
+	it := Seq()
+	it.DrainN(1000)
+	if x, _ := <-it; x != 1000 {
+		t.Errorf("Seq().DrainN(1000): expecting the next element to be 1000, got %d", x)
+	}
+}
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		s        []int
+		expected float64
+		ok       bool
+	}{
+		{nil, 0, false},
+		{[]int{5}, 5, true},
+		{[]int{9, 1, 5}, 5, true},
+		{[]int{4, 1, 3, 2}, 2.5, true},
+		{[]int{7, 7, 7, 7}, 7, true},
+		{[]int{math.MaxInt, math.MaxInt - 2}, math.MaxInt - 1, true},
+	}
+	for _, test := range tests {
+		if actual, ok := Median(itertest.FromSlice(test.s)); actual != test.expected || ok != test.ok {
+			t.Errorf("Median() of %v: expecting (%v, %t), got (%v, %t)", test.s, test.expected, test.ok, actual, ok)
+		}
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	for _, size := range []int{1, 2, 7, 100, 101} {
+		s := Random(rand.New(rand.NewSource(int64(size))), -50, 50).CollectN(size)
+		sorted := append([]int(nil), s...)
+		sort.Ints(sorted)
+		for _, q := range []float64{0, 0.1, 0.25, 0.5, 0.9, 1} {
+			rank := int(math.Ceil(q * float64(size)))
+			if rank < 1 {
+				rank = 1
+			}
+			expected := sorted[rank-1]
+			if actual, ok := Quantile(itertest.FromSlice(s), q); actual != expected || !ok {
+				t.Errorf("Quantile(%v) of %v: expecting (%d, true), got (%d, %t)", q, s, expected, actual, ok)
+			}
+		}
+	}
+	if _, ok := Quantile(itertest.FromSlice(ints(0)), 0.5); ok {
+		t.Errorf("Quantile(0.5) of an empty Iter: expecting ok = false")
+	}
+}
+
+func TestQuantileRoundingOfRank(t *testing.T) {
+	// q*100 is not exact for these q, e.g. 0.07*100 is 7.000000000000001
+	tests := []struct {
+		q        float64
+		expected int
+	}{
+		{0.07, 7},
+		{0.29, 29},
+		{0.57, 57},
+		{0.575, 58},
+		{0.01, 1},
+		{0.99, 99},
+	}
+	for _, test := range tests {
+		if actual, ok := Quantile(RangeInclusive(1, 100), test.q); actual != test.expected || !ok {
+			t.Errorf("Quantile(%v) of 1 ~ 100: expecting (%d, true), got (%d, %t)", test.q, test.expected, actual, ok)
+		}
+	}
+}
+
+func TestQuantileOutOfRangePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Quantile(1.5): expecting a panic")
+		}
+	}()
+	Quantile(itertest.FromSlice(ints(10)), 1.5)
+}
+
+func BenchmarkQuantileSelect(b *testing.B) {
+	data := Random(rand.New(rand.NewSource(1)), 0, 1000000).CollectN(1000000)
+	s := make([]int, len(data))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		copy(s, data)
+		SelectKth(s, len(s)*9/10)
+	}
+}
+
+func BenchmarkQuantileSort(b *testing.B) {
+	data := Random(rand.New(rand.NewSource(1)), 0, 1000000).CollectN(1000000)
+	s := make([]int, len(data))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		copy(s, data)
+		sort.Ints(s)
+		_ = s[len(s)*9/10]
+	}
+}
+
+func TestQuantileEstUniform(t *testing.T) {
+	const n = 100000
+	for _, q := range []float64{0.1, 0.25, 0.5, 0.75, 0.9, 0.99} {
+		s := Random(rand.New(rand.NewSource(42)), 0, 10000).CollectN(n)
+		exact, _ := Quantile(itertest.FromSlice(s), q)
+		estimate := QuantileEst(itertest.FromSlice(s), q)
+		if math.Abs(estimate-float64(exact)) > 0.02*10000 {
+			t.Errorf("QuantileEst(%v): expecting about %d, got %v", q, exact, estimate)
+		}
+	}
+}
+
+func TestQuantileEstSmall(t *testing.T) {
+	tests := []struct {
+		s        []int
+		q        float64
+		expected float64
+	}{
+		{nil, 0.5, 0},
+		{[]int{7}, 0.5, 7},
+		{[]int{5, 1, 4}, 0.5, 4},
+		{[]int{5, 1, 4, 2, 3}, 0, 1},
+		{[]int{5, 1, 4, 2, 3}, 1, 5},
+		{[]int{5, 1, 4, 2, 3, 9, -3}, 0, -3},
+		{[]int{5, 1, 4, 2, 3, 9, -3}, 1, 9},
+	}
+	for _, test := range tests {
+		if actual := QuantileEst(itertest.FromSlice(test.s), test.q); actual != test.expected {
+			t.Errorf("QuantileEst(%v) of %v: expecting %v, got %v", test.q, test.s, test.expected, actual)
+		}
+	}
+}
+
+func TestQuantileSketchDeterministic(t *testing.T) {
+	s := Random(rand.New(rand.NewSource(7)), -1000, 1000).CollectN(10000)
+	a, b := NewQuantileSketch(0.3), NewQuantileSketch(0.3)
+	itertest.FromSlice(s).ForEach(a.Observe)
+	itertest.FromSlice(s).ForEach(b.Observe)
+	if a.Value() != b.Value() || a.Count() != len(s) {
+		t.Errorf("QuantileSketch: expecting identical estimates over %d observations, got %v and %v after %d",
+			len(s), a.Value(), b.Value(), a.Count())
+	}
+}
+
+func TestQuantileSketchMonotonicData(t *testing.T) {
+	sketch := NewQuantileSketch(0.5)
+	Range(0, 10001).ForEach(sketch.Observe)
+	if v := sketch.Value(); math.Abs(v-5000) > 100 {
+		t.Errorf("QuantileSketch over 0..10000: expecting about 5000, got %v", v)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	tests := []struct {
+		s        []int
+		width    int
+		expected map[int]int
+	}{
+		{nil, 10, map[int]int{}},
+		{[]int{0, 9, 10, 19, 20}, 10, map[int]int{0: 2, 10: 2, 20: 1}},
+		{[]int{-1, -10, -11, 0, 1}, 10, map[int]int{-10: 2, -20: 1, 0: 2}},
+		{[]int{-3, -2, -1, 0, 1, 2, 3}, 2, map[int]int{-4: 1, -2: 2, 0: 2, 2: 2}},
+		{[]int{5, 5, 5}, 1, map[int]int{5: 3}},
+		{[]int{math.MinInt, math.MinInt + 7, math.MinInt + 8, math.MinInt + 17}, 10, map[int]int{math.MinInt: 2, math.MinInt + 8: 2}},
+		{[]int{math.MinInt, math.MaxInt}, 1 << 62, map[int]int{math.MinInt: 1, 1 << 62: 1}},
+		{[]int{math.MinInt, math.MaxInt}, math.MaxInt, map[int]int{math.MinInt: 1, math.MaxInt: 1}},
+	}
+	for _, test := range tests {
+		if actual := Histogram(itertest.FromSlice(test.s), test.width); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("Histogram(%d) of %v: expecting %v, got %v", test.width, test.s, test.expected, actual)
+		}
+	}
+}
+
+func TestHistogramNonPositiveWidthPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Histogram(0): expecting a panic")
+		}
+	}()
+	Histogram(itertest.FromSlice(ints(10)), 0)
+}
+
+func TestHistogramBounds(t *testing.T) {
+	tests := []struct {
+		s        []int
+		bounds   []int
+		expected []int
+	}{
+		{nil, []int{0, 10}, []int{0, 0, 0}},
+		{[]int{-5, 0, 5, 10, 15}, []int{0, 10}, []int{1, 2, 2}},
+		{[]int{-11, -10, -9, -1, 0}, []int{-10, 0}, []int{1, 3, 1}},
+		{[]int{1, 2, 3}, nil, []int{3}},
+		{[]int{1, 2, 3}, []int{2}, []int{1, 2}},
+	}
+	for _, test := range tests {
+		if actual := HistogramBounds(itertest.FromSlice(test.s), test.bounds); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("HistogramBounds(%v) of %v: expecting %v, got %v", test.bounds, test.s, test.expected, actual)
+		}
+	}
+}
+
+func TestHistogramBoundsUnsortedPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("HistogramBounds([]int{1, 1}): expecting a panic")
+		}
+	}()
+	HistogramBounds(itertest.FromSlice(ints(10)), []int{1, 1})
+}
+
+func TestMode(t *testing.T) {
+	tests := []struct {
+		s             []int
+		mode, count   int
+		ok            bool
+		expectedModes []int
+	}{
+		{nil, 0, 0, false, nil},
+		{[]int{1, 2, 2, 3, 2, 1}, 2, 3, true, []int{2}},
+		{[]int{3, 1, 1, 3, 2, 2}, 3, 2, true, []int{3, 1, 2}},
+		{[]int{4, 2, 9, 7}, 4, 1, true, []int{4, 2, 9, 7}},
+		{[]int{5, 6, 6, 5}, 5, 2, true, []int{5, 6}},
+	}
+	for _, test := range tests {
+		if mode, count, ok := Mode(itertest.FromSlice(test.s)); mode != test.mode || count != test.count || ok != test.ok {
+			t.Errorf("Mode() of %v: expecting (%d, %d, %t), got (%d, %d, %t)",
+				test.s, test.mode, test.count, test.ok, mode, count, ok)
+		}
+		if actual := Modes(itertest.FromSlice(test.s)); !reflect.DeepEqual(actual, test.expectedModes) {
+			t.Errorf("Modes() of %v: expecting %v, got %v", test.s, test.expectedModes, actual)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	if a, b := Hash(itertest.FromSlice(ints(1000))), Hash(itertest.FromSlice(ints(1000))); a != b {
+		t.Errorf("Hash() of the same stream twice: expecting equal digests, got %d and %d", a, b)
+	}
+	if a, b := Hash(itertest.FromSlice([]int{1, 2, 3, 4})), Hash(itertest.FromSlice([]int{1, 3, 2, 4})); a == b {
+		t.Errorf("Hash() of [1 2 3 4] and [1 3 2 4]: expecting different digests, got %d for both", a)
+	}
+	if a, b := Hash(itertest.FromSlice([]int{0})), Hash(itertest.FromSlice(ints(0))); a == b {
+		t.Errorf("Hash() of [0] and []: expecting different digests, got %d for both", a)
+	}
+	if expected, actual := uint64(14695981039346656037), Hash(itertest.FromSlice(ints(0))); actual != expected {
+		t.Errorf("Hash() of an empty Iter: expecting %d, got %d", expected, actual)
+	}
+	// FNV-1a of the bytes 00 00 00 00 00 00 00 01
+	h := fnv.New64a()
+	h.Write([]byte{0, 0, 0, 0, 0, 0, 0, 1})
+	if expected, actual := h.Sum64(), Hash(itertest.FromSlice([]int{1})); actual != expected {
+		t.Errorf("Hash() of [1]: expecting %d, got %d", expected, actual)
+	}
+}
+
+func TestHashWith(t *testing.T) {
+	expected := fnv.New64().Sum64()
+	if actual := HashWith(itertest.FromSlice(ints(0)), fnv.New64()); actual != expected {
+		t.Errorf("HashWith(fnv.New64()) of an empty Iter: expecting %d, got %d", expected, actual)
+	}
+	if a, b := HashWith(itertest.FromSlice(ints(100)), fnv.New64()), Hash(itertest.FromSlice(ints(100))); a == b {
+		t.Errorf("HashWith(fnv.New64()) and Hash(): expecting different digests, got %d for both", a)
+	}
+	if a, b := HashWith(itertest.FromSlice(ints(100)), fnv.New64()), HashWith(itertest.FromSlice(ints(100)), fnv.New64()); a != b {
+		t.Errorf("HashWith(fnv.New64()) of the same stream twice: expecting equal digests, got %d and %d", a, b)
+	}
+}
+
+func TestZip(t *testing.T) {
+	tests := []struct {
+		a, b     []int
+		expected []Pair[int, int]
+	}{
+		{nil, nil, nil},
+		{[]int{1, 2, 3}, []int{4, 5, 6}, []Pair[int, int]{{1, 4}, {2, 5}, {3, 6}}},
+		{[]int{1, 2, 3}, []int{4}, []Pair[int, int]{{1, 4}}},
+		{[]int{1}, []int{4, 5, 6}, []Pair[int, int]{{1, 4}}},
+	}
+	for _, test := range tests {
+		var actual []Pair[int, int]
+		for p := range Zip(itertest.FromSlice(test.a), itertest.FromSlice(test.b)) {
+			actual = append(actual, p)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("Zip(%v, %v): expecting %v, got %v", test.a, test.b, test.expected, actual)
+		}
+	}
+}
+
+func TestUnzip(t *testing.T) {
+	tests := []struct {
+		a, b []int
+	}{
+		{nil, nil},
+		{[]int{1}, []int{-1}},
+		{[]int{1, 2, 3, 4, 5}, []int{10, 20, 30, 40, 50}},
+		{ints(1000), itertest.FromSlice(ints(1000)).Map(func(x int) int { return -x }).Collect()},
+	}
+	for _, test := range tests {
+		firsts, seconds := Unzip(Zip(itertest.FromSlice(test.a), itertest.FromSlice(test.b)))
+		var wg sync.WaitGroup
+		var actualA, actualB []int
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			actualA = firsts.Collect()
+		}()
+		go func() {
+			defer wg.Done()
+			actualB = seconds.Collect()
+		}()
+		wg.Wait()
+		if len(actualA) != len(test.a) || (len(test.a) > 0 && !reflect.DeepEqual(actualA, test.a)) {
+			t.Errorf("Unzip firsts: expecting %v, got %v", test.a, actualA)
+		}
+		if len(actualB) != len(test.b) || (len(test.b) > 0 && !reflect.DeepEqual(actualB, test.b)) {
+			t.Errorf("Unzip seconds: expecting %v, got %v", test.b, actualB)
+		}
+	}
+}
+
+func TestUnzipOneSideOnly(t *testing.T) {
+	firsts, seconds := Unzip(Zip(itertest.FromSlice(ints(100)), itertest.FromSlice(ints(100))))
+	itertest.AssertEqual(t, firsts, ints(100))
+	itertest.AssertEqual(t, seconds, ints(100))
+}
+
+func TestUnzipBuffersOnlyTheLag(t *testing.T) {
+	size := 1000
+	it, src := countingSource(size)
+	defer src.close()
+	firsts, seconds := Unzip(Zip(it, Seq()))
+	for i := 0; i < size; i++ {
+		xa, xb := <-firsts, <-seconds
+		if xa != i || xb != i {
+			t.Fatalf("Unzip() in lockstep: expecting %d from both sides, got %d and %d", i, xa, xb)
+		}
+		// Unzip and Zip may each have received one more pair than the sides need
+		if n := src.received(); n > int64(i+3) {
+			t.Fatalf("Unzip() in lockstep: expecting at most %d elements received from the source, got %d", i+3, n)
+		}
+	}
+	firsts.Close()
+	seconds.Close()
+}
+
+// countingSource returns an Iter of 0, 1, ..., size-1 backed by a plain unbuffered channel, and its source to
+// count the elements actually received from it, since a send on an unbuffered channel completes only when received.
+// The source must be closed at the end of the test unless all of its elements are received.
+func countingSource(size int) (Iter[int], *source) {
+	ch := make(chan int)
+	src := &source{ask: make(chan chan int64), stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(src.done)
+		defer close(ch)
+		for src.sent < int64(size) {
+			select {
+			case ch <- int(src.sent):
+				src.sent++
+			case reply := <-src.ask:
+				reply <- src.sent
+			case <-src.stop:
+				return
+			}
+		}
+	}()
+	return ch, src
+}
+
+// source is the sending side of a countingSource.
+type source struct {
+	ask  chan chan int64
+	stop chan struct{}
+	done chan struct{}
+	// sent is only accessed by the goroutine of the source until done is closed
+	sent int64
+}
+
+// received returns the number of elements received from the source so far. It is answered by the goroutine
+// of the source between two sends, so that a send that has completed is always counted.
+func (src *source) received() int64 {
+	reply := make(chan int64)
+	select {
+	case src.ask <- reply:
+		return <-reply
+	case <-src.done:
+		return src.sent
+	}
+}
+
+// close stops the source, which then ends the Iter, and waits for its goroutine to exit.
+func (src *source) close() {
+	close(src.stop)
+	<-src.done
+}
+
+func TestCloseReleasesPipeline(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func()
+	}{
+		{"Take", func() {
+			Range(0, 1000000).Map(func(x int) int { return x * x }).Take(3).Collect()
+		}},
+		{"Close after partial consumption", func() {
+			it := Seq().Filter(func(x int) bool { return x%2 == 0 }).Map(func(x int) int { return x + 1 })
+			for x := range it {
+				if x > 10 {
+					break
+				}
+			}
+			it.Close()
+		}},
+		{"Find", func() {
+			Seq().Map(func(x int) int { return x * 3 }).Find(func(x int) bool { return x > 100 })
+		}},
+		{"Any", func() {
+			Primes().Any(func(x int) bool { return x > 1000 })
+		}},
+		{"Equal", func() {
+			Equal(Seq(), Seq().Drop(1))
+		}},
+		{"TryForEach", func() {
+			Random(rand.New(rand.NewSource(1)), 0, 10).TryForEach(func(int) error { return errWriteFailed })
+		}},
+		{"Zip", func() {
+			p := Zip(Seq(), Repeat(1))
+			<-p
+			p.Close()
+		}},
+		{"Unzip", func() {
+			firsts, seconds := Unzip(Zip(Seq(), Seq()))
+			<-firsts
+			firsts.Close()
+			seconds.Close()
+		}},
+		{"FromChan", func() {
+			it := FromChan(make(chan int)).Map(func(x int) int { return x })
+			it.Close()
+		}},
+		{"Tick", func() {
+			it := Tick(time.Millisecond)
+			<-it
+			it.Close()
+		}},
+	}
+	for _, test := range tests {
+		itertest.CheckNoLeaks(t, test.run)
+	}
+}
+
+func TestCloseEndsRangeLoop(t *testing.T) {
+	it := Seq()
+	<-it
+	it.Close()
+	// at most one element already being sent may still be received
+	if rest := it.Count(); rest > 1 {
+		t.Errorf("Close(): expecting the Iter to be closed, got %d more elements", rest)
+	}
+}
+
+func TestCloseIdempotent(t *testing.T) {
+	it := Range(0, 10)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			it.Close()
+		}()
+	}
+	wg.Wait()
+	it.Close()
+	itertest.FromSlice(ints(0)).Close()
+	Iter[int](make(chan int)).Close()
+}
+
+func TestFirstLeavesRest(t *testing.T) {
+	it := itertest.FromSlice(ints(5))
+	it.First()
+	itertest.AssertEqual(t, it, []int{1, 2, 3, 4})
+}
+
+func TestWithContext(t *testing.T) {
+	tests := []struct {
+		name string
+		it   func(ctx context.Context) Iter[int]
+	}{
+		{"RangeCtx", func(ctx context.Context) Iter[int] { return RangeCtx(ctx, 0, 1000000) }},
+		{"SeqCtx", func(ctx context.Context) Iter[int] { return SeqCtx(ctx) }},
+		{"WithContext", func(ctx context.Context) Iter[int] {
+			return Seq().Map(func(x int) int { return x }).Filter(func(int) bool { return true }).WithContext(ctx)
+		}},
+	}
+	for _, test := range tests {
+		itertest.CheckNoLeaks(t, func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			it := test.it(ctx)
+			if expected, actual := []int{0, 1, 2, 3, 4}, it.CollectN(5); !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%s: expecting %v before cancellation, got %v", test.name, expected, actual)
+			}
+			cancel()
+			done := make(chan int)
+			go func() {
+				done <- it.Count()
+			}()
+			select {
+			case rest := <-done:
+				// an element already being received when ctx is cancelled may still be delivered
+				if rest > 1 {
+					t.Errorf("%s: expecting no elements after cancellation, got %d", test.name, rest)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("%s: expecting the Iter to be closed promptly after cancellation", test.name)
+			}
+		})
+	}
+}
+
+func TestWithContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if actual := RangeCtx(ctx, 0, 10).Collect(); actual != nil {
+		t.Errorf("RangeCtx (cancelled): expecting an empty Iter, got %v", actual)
+	}
+	if expected, actual := ints(10), RangeCtx(context.Background(), 0, 10).Collect(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("RangeCtx (not cancelled): expecting %v, got %v", expected, actual)
+	}
+}
+
+func TestSeqStopsOnOverflow(t *testing.T) {
+	tests := []struct {
+		start, step int
+		expected    []int
+	}{
+		{math.MaxInt - 2, 1, []int{math.MaxInt - 2, math.MaxInt - 1, math.MaxInt}},
+		{math.MaxInt - 4, 2, []int{math.MaxInt - 4, math.MaxInt - 2, math.MaxInt}},
+		{math.MinInt + 5, -3, []int{math.MinInt + 5, math.MinInt + 2}},
+		{math.MaxInt, 1, []int{math.MaxInt}},
+	}
+	for _, test := range tests {
+		if actual := SeqFrom(test.start, test.step).Collect(); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("SeqFrom(%d, %d): expecting %v, got %v", test.start, test.step, test.expected, actual)
+		}
+	}
+}
+
+func TestSeqReleasedWhenDropped(t *testing.T) {
+	itertest.CheckNoLeaks(t, func() {
+		it := Seq().Map(func(x int) int { return x * 2 })
+		it.CollectN(10)
+		it.Close()
+	})
+}
+
+func TestTakeConsumesExactly(t *testing.T) {
+	tests := []struct {
+		size, n, expected int
+	}{
+		{10, 0, 0},
+		{10, 3, 3},
+		{10, 10, 10},
+		{10, 20, 10},
+		{0, 5, 0},
+	}
+	for _, test := range tests {
+		it, src := countingSource(test.size)
+		actual := it.Take(test.n).Collect()
+		if len(actual) != test.expected {
+			t.Errorf("Take(%d), size = %d: expecting %d elements, got %v", test.n, test.size, test.expected, actual)
+		}
+		// Take has stopped receiving once its Iter has ended
+		if n := src.received(); n != int64(test.expected) {
+			t.Errorf("Take(%d), size = %d: expecting %d elements received from upstream, got %d", test.n, test.size, test.expected, n)
+		}
+		src.close()
+	}
+}
+
+func TestTakeZeroIsClosed(t *testing.T) {
+	itertest.CheckNoLeaks(t, func() {
+		it := Seq().Take(0)
+		if x, ok := <-it; ok {
+			t.Errorf("Take(0): expecting a closed Iter, got element %d", x)
+		}
+	})
+}
+
+func TestTakeDropRangeEdgeCases(t *testing.T) {
+	tests := []struct {
+		name     string
+		it       func() Iter[int]
+		expected []int
+	}{
+		{"Take(0)", func() Iter[int] { return itertest.FromSlice(ints(5)).Take(0) }, nil},
+		{"Take(5)", func() Iter[int] { return itertest.FromSlice(ints(5)).Take(5) }, []int{0, 1, 2, 3, 4}},
+		{"Drop(0)", func() Iter[int] { return itertest.FromSlice(ints(5)).Drop(0) }, []int{0, 1, 2, 3, 4}},
+		{"Drop(5)", func() Iter[int] { return itertest.FromSlice(ints(5)).Drop(5) }, nil},
+		{"Range(5, 1)", func() Iter[int] { return Range(5, 1) }, nil},
+		{"Range(3, 3)", func() Iter[int] { return Range(3, 3) }, nil},
+		{"Range(-2, 1)", func() Iter[int] { return Range(-2, 1) }, []int{-2, -1, 0}},
+		{"RangeInclusive(3, 2)", func() Iter[int] { return RangeInclusive(3, 2) }, nil},
+		{"RepeatN(7, -1)", func() Iter[int] { return RepeatN(7, -1) }, nil},
+	}
+	for _, test := range tests {
+		if actual := test.it().Collect(); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: expecting %v, got %v", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestEmptyCasesStartNoGoroutine(t *testing.T) {
+	tests := []struct {
+		name string
+		it   func() Iter[int]
+	}{
+		{"Range(5, 1)", func() Iter[int] { return Range(5, 1) }},
+		{"Range(0, 0)", func() Iter[int] { return Range(0, 0) }},
+		{"RangeInclusive(1, 0)", func() Iter[int] { return RangeInclusive(1, 0) }},
+		{"RepeatN(1, 0)", func() Iter[int] { return RepeatN(1, 0) }},
+		{"Take(0)", func() Iter[int] { return Range(0, 0).Take(0) }},
+	}
+	for _, test := range tests {
+		before := runtime.NumGoroutine()
+		it := test.it()
+		if after := runtime.NumGoroutine(); after != before {
+			t.Errorf("%s: expecting no goroutine to be started, got %d goroutines, expecting %d", test.name, after, before)
+		}
+		if x, ok := <-it; ok {
+			t.Errorf("%s: expecting a closed Iter, got element %d", test.name, x)
+		}
+	}
+}
+
+func TestTakeDropNegativePanics(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"Take", func() { itertest.FromSlice(ints(5)).Take(-1) }},
+		{"Drop", func() { itertest.FromSlice(ints(5)).Drop(-5) }},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				r := recover()
+				if msg, ok := r.(string); !ok || !strings.HasPrefix(msg, test.name+":") {
+					t.Errorf("%s(negative): expecting a panic naming %s, got %v", test.name, test.name, r)
+				}
+			}()
+			test.fn()
+		}()
+	}
+}
+
+// pullRange is a PullIter of the integers [from, to) without any channel behind it.
+func pullRange(from, to int) PullIter[int] {
+	return PullIter[int]{Next: func() (int, bool) {
+		if from >= to {
+			return 0, false
+		}
+		from++
+		return from - 1, true
+	}}
+}
+
+func TestPullIterMatchesIter(t *testing.T) {
+	square := func(x int) int { return x * x }
+	odd := func(x int) bool { return x%2 == 1 }
+	tests := []struct {
+		name string
+		iter func(Iter[int]) Iter[int]
+		pull func(PullIter[int]) PullIter[int]
+	}{
+		{"Map", func(it Iter[int]) Iter[int] { return it.Map(square) }, func(p PullIter[int]) PullIter[int] { return p.Map(square) }},
+		{"Filter", func(it Iter[int]) Iter[int] { return it.Filter(odd) }, func(p PullIter[int]) PullIter[int] { return p.Filter(odd) }},
+		{"Take(0)", func(it Iter[int]) Iter[int] { return it.Take(0) }, func(p PullIter[int]) PullIter[int] { return p.Take(0) }},
+		{"Take(7)", func(it Iter[int]) Iter[int] { return it.Take(7) }, func(p PullIter[int]) PullIter[int] { return p.Take(7) }},
+		{"Take(1000)", func(it Iter[int]) Iter[int] { return it.Take(1000) }, func(p PullIter[int]) PullIter[int] { return p.Take(1000) }},
+		{"Drop(7)", func(it Iter[int]) Iter[int] { return it.Drop(7) }, func(p PullIter[int]) PullIter[int] { return p.Drop(7) }},
+		{"Drop(1000)", func(it Iter[int]) Iter[int] { return it.Drop(1000) }, func(p PullIter[int]) PullIter[int] { return p.Drop(1000) }},
+		{"pipeline", func(it Iter[int]) Iter[int] {
+			return it.Drop(3).Filter(odd).Map(square).Take(10)
+		}, func(p PullIter[int]) PullIter[int] {
+			return p.Drop(3).Filter(odd).Map(square).Take(10)
+		}},
+	}
+	for _, size := range []int{0, 1, 50} {
+		for _, test := range tests {
+			expected := test.iter(itertest.FromSlice(ints(size))).Collect()
+			if actual := test.pull(pullRange(0, size)).Collect(); !reflect.DeepEqual(actual, expected) {
+				t.Errorf("PullIter %s, size = %d: expecting %v, got %v", test.name, size, expected, actual)
+			}
+			if actual := test.pull(itertest.FromSlice(ints(size)).Pull()).Chan().Collect(); !reflect.DeepEqual(actual, expected) {
+				t.Errorf("Pull().%s.Chan(), size = %d: expecting %v, got %v", test.name, size, expected, actual)
+			}
+			sum := func(acc, cur int) int { return acc + cur }
+			if expected, actual := test.iter(itertest.FromSlice(ints(size))).Reduce(0, sum), test.pull(pullRange(0, size)).Reduce(0, sum); actual != expected {
+				t.Errorf("PullIter %s.Reduce, size = %d: expecting %d, got %d", test.name, size, expected, actual)
+			}
+		}
+	}
+}
+
+func TestPullIterTakeCallsNextExactly(t *testing.T) {
+	calls := 0
+	p := PullIter[int]{Next: func() (int, bool) {
+		calls++
+		return calls, true
+	}}
+	if expected, actual := []int{1, 2, 3}, p.Take(3).Collect(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("PullIter Take(3): expecting %v, got %v", expected, actual)
+	}
+	if calls != 3 {
+		t.Errorf("PullIter Take(3): expecting Next to be called 3 times, got %d", calls)
+	}
+}
+
+func TestPullIterChanClose(t *testing.T) {
+	itertest.CheckNoLeaks(t, func() {
+		it := PullIter[int]{Next: func() (int, bool) { return 1, true }}.Chan()
+		<-it
+		it.Close()
+	})
+}
+
+func BenchmarkPipelineIter(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Range(0, 1000000).
+			Map(func(x int) int { return x * 3 }).
+			Filter(func(x int) bool { return x%2 == 0 }).
+			Map(func(x int) int { return x + 1 }).
+			Drop(10).
+			Take(400000).
+			Reduce(0, func(acc, cur int) int { return acc + cur })
+	}
+}
+
+func BenchmarkPipelinePullIter(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		pullRange(0, 1000000).
+			Map(func(x int) int { return x * 3 }).
+			Filter(func(x int) bool { return x%2 == 0 }).
+			Map(func(x int) int { return x + 1 }).
+			Drop(10).
+			Take(400000).
+			Reduce(0, func(acc, cur int) int { return acc + cur })
+	}
+}
+
+func TestBatched(t *testing.T) {
+	double := func(x int) int { return x * 2 }
+	notMultipleOf3 := func(x int) bool { return x%3 != 0 }
+	for _, size := range []int{0, 1, 63, 64, 65, 1000} {
+		for _, batchSize := range []int{1, 7, 64} {
+			expected := itertest.FromSlice(ints(size)).Map(double).Filter(notMultipleOf3).Collect()
+			if actual := itertest.FromSlice(ints(size)).Batched(batchSize).Map(double).Filter(notMultipleOf3).Collect(); !reflect.DeepEqual(actual, expected) {
+				t.Errorf("Batched(%d), size = %d: expecting %v, got %v", batchSize, size, expected, actual)
+			}
+			if actual := itertest.FromSlice(ints(size)).Batched(batchSize).Map(double).Unbatch().Filter(notMultipleOf3).Collect(); !reflect.DeepEqual(actual, expected) {
+				t.Errorf("Batched(%d).Unbatch(), size = %d: expecting %v, got %v", batchSize, size, expected, actual)
+			}
+			sum := func(acc, cur int) int { return acc + cur }
+			if expected, actual := itertest.FromSlice(ints(size)).Reduce(0, sum), itertest.FromSlice(ints(size)).Batched(batchSize).Reduce(0, sum); actual != expected {
+				t.Errorf("Batched(%d).Reduce, size = %d: expecting %d, got %d", batchSize, size, expected, actual)
+			}
+		}
+	}
+}
+
+func TestBatchedSizes(t *testing.T) {
+	var sizes []int
+	for batch := range itertest.FromSlice(ints(10)).Batched(4) {
+		sizes = append(sizes, len(batch))
+	}
+	if expected := []int{4, 4, 2}; !reflect.DeepEqual(sizes, expected) {
+		t.Errorf("Batched(4), size = 10: expecting batch sizes %v, got %v", expected, sizes)
+	}
+}
+
+func TestBatchedClose(t *testing.T) {
+	itertest.CheckNoLeaks(t, func() {
+		it := Seq().Batched(64).Map(func(x int) int { return x + 1 }).Unbatch()
+		it.CollectN(100)
+		it.Close()
+	})
+}
+
+func BenchmarkUnbatched(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Range(0, 1000000).
+			Map(func(x int) int { return x * 3 }).
+			Filter(func(x int) bool { return x%2 == 0 }).
+			Reduce(0, func(acc, cur int) int { return acc + cur })
+	}
+}
+
+func BenchmarkBatched(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Range(0, 1000000).
+			Batched(64).
+			Map(func(x int) int { return x * 3 }).
+			Filter(func(x int) bool { return x%2 == 0 }).
+			Reduce(0, func(acc, cur int) int { return acc + cur })
+	}
+}
+
+func TestMapToOtherType(t *testing.T) {
+	expected := []string{"0", "1", "2", "3", "4"}
+	itertest.AssertEqual(t, Map(Range(0, 5), strconv.Itoa), expected)
+	if actual := Map(itertest.FromSlice(ints(0)), strconv.Itoa).Collect(); actual != nil {
+		t.Errorf("Map(itertest.FromSlice(ints(0)), strconv.Itoa): expecting nil, got %v", actual)
+	}
+}
+
+func TestReduceToOtherType(t *testing.T) {
+	words := FromSlices([][]string{{"go", "iter"}, {"chan"}})
+	totalLen := func(acc int, s string) int { return acc + len(s) }
+	if expected, actual := 10, Reduce(words, 0, totalLen); actual != expected {
+		t.Errorf("Reduce(words, 0, totalLen): expecting %d, got %d", expected, actual)
+	}
+}
+
+func TestStringElements(t *testing.T) {
+	words := []string{"apple", "kiwi", "banana", "fig", "kiwi"}
+	fromWords := func() Iter[string] { return FromSlices([][]string{words}) }
+
+	long := func(s string) bool { return len(s) > 3 }
+	expected := []string{"APPLE", "KIWI", "BANANA"}
+	itertest.AssertEqual(t, fromWords().Filter(long).Map(strings.ToUpper).Take(3), expected)
+	if min, max, ok := MinMax(fromWords()); min != "apple" || max != "kiwi" || !ok {
+		t.Errorf("MinMax: expecting (apple, kiwi, true), got (%s, %s, %t)", min, max, ok)
+	}
+	if x, ok := Map(itertest.FromSlice(ints(0)), strconv.Itoa).First(); x != "" || ok {
+		t.Errorf("First of empty: expecting (\"\", false), got (%q, %t)", x, ok)
+	}
+	if !Contains(fromWords(), "fig") || Contains(fromWords(), "pear") {
+		t.Errorf("Contains: expecting fig and not pear")
+	}
+	if expected, actual := map[string]int{"apple": 1, "kiwi": 2, "banana": 1, "fig": 1}, Frequencies(fromWords()); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Frequencies: expecting %v, got %v", expected, actual)
+	}
+	if expected, actual := "apple,kiwi,banana,fig,kiwi", fromWords().JoinString(","); actual != expected {
+		t.Errorf("JoinString(\",\"): expecting %q, got %q", expected, actual)
+	}
+}
+
+func TestStructElements(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+	points := []point{{1, 2}, {-3, 0}, {2, 2}, {0, 5}}
+	fromPoints := func() Iter[point] { return FromSlices([][]point{points}) }
+	norm := func(p point) int { return p.X*p.X + p.Y*p.Y }
+
+	if p, ok := MaxBy(fromPoints(), norm); p != (point{0, 5}) || !ok {
+		t.Errorf("MaxBy(norm): expecting ({0 5}, true), got (%v, %t)", p, ok)
+	}
+	if p, ok := fromPoints().Find(func(p point) bool { return p.X == p.Y }); p != (point{2, 2}) || !ok {
+		t.Errorf("Find(X == Y): expecting ({2 2}, true), got (%v, %t)", p, ok)
+	}
+	expectedGroups := map[int][]point{2: {{1, 2}, {2, 2}}, 0: {{-3, 0}}, 5: {{0, 5}}}
+	if actual := GroupByToMap(fromPoints(), func(p point) int { return p.Y }); !reflect.DeepEqual(expectedGroups, actual) {
+		t.Errorf("GroupByToMap(Y): expecting %v, got %v", expectedGroups, actual)
+	}
+	if !Equal(fromPoints(), FromSlices([][]point{points[:2], points[2:]})) {
+		t.Errorf("Equal: expecting true")
+	}
+	if expected, actual := 47, Sum(Map(fromPoints(), norm)); actual != expected {
+		t.Errorf("Sum(Map(norm)): expecting %d, got %d", expected, actual)
+	}
+
+	var buf bytes.Buffer
+	if err := fromPoints().Take(2).EncodeJSON(&buf); err != nil {
+		t.Fatalf("EncodeJSON: unexpected error %v", err)
+	}
+	if expected := `[{"X":1,"Y":2},{"X":-3,"Y":0}]`; buf.String() != expected {
+		t.Errorf("EncodeJSON: expecting %s, got %s", expected, buf.String())
+	}
+
+	labels := FromSlices([][]string{{"a", "b", "c"}})
+	expectedPairs := []Pair[point, string]{{point{1, 2}, "a"}, {point{-3, 0}, "b"}, {point{2, 2}, "c"}}
+	var actualPairs []Pair[point, string]
+	for p := range Zip(fromPoints(), labels) {
+		actualPairs = append(actualPairs, p)
+	}
+	if !reflect.DeepEqual(expectedPairs, actualPairs) {
+		t.Errorf("Zip(points, labels): expecting %v, got %v", expectedPairs, actualPairs)
+	}
+}
+
+func TestMapToStringAndFloat(t *testing.T) {
+	tests := []struct {
+		name     string
+		it       Iter[int]
+		expected []string
+	}{
+		{"Range(1, 5)", Range(1, 5), []string{"1", "2", "3", "4"}},
+		{"itertest.FromSlice(ints(0))", itertest.FromSlice(ints(0)), nil},
+		{"Filter(odd).Take(3)", Seq().Filter(func(x int) bool { return x%2 == 1 }).Take(3), []string{"1", "3", "5"}},
+	}
+	for _, test := range tests {
+		if actual := Map(test.it, strconv.Itoa).Collect(); !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf("Map(%s, strconv.Itoa): expecting %v, got %v", test.name, test.expected, actual)
+		}
+	}
+
+	sqrt := func(x int) float64 { return math.Sqrt(float64(x)) }
+	expected := []float64{0, 1, 2, 3}
+	itertest.AssertEqual(t, Map(Range(0, 10).Filter(func(x int) bool { return x == 0 || x == 1 || x == 4 || x == 9 }), sqrt), expected)
+	if mean := Stats(Range(1, 101)).Mean; Sum(Map(Range(1, 101), func(x int) float64 { return float64(x) / 100 })) != mean {
+		t.Errorf("Sum(Map(Range(1, 101), x / 100)): expecting %v", mean)
+	}
+}
+
+func TestToSeq(t *testing.T) {
+	var actual []int
+	for x := range Range(0, 5).ToSeq() {
+		actual = append(actual, x)
+	}
+	if expected := []int{0, 1, 2, 3, 4}; !reflect.DeepEqual(expected, actual) {
+		t.Errorf("ToSeq(): expecting %v, got %v", expected, actual)
+	}
+
+	itertest.CheckNoLeaks(t, func() {
+		actual = nil
+		for x := range Seq().Map(func(x int) int { return x * x }).ToSeq() {
+			if x > 10 {
+				break
+			}
+			actual = append(actual, x)
+		}
+		if expected := []int{0, 1, 4, 9}; !reflect.DeepEqual(expected, actual) {
+			t.Errorf("ToSeq() with break: expecting %v, got %v", expected, actual)
+		}
+	})
+}
+
+func TestFromSeq(t *testing.T) {
+	tests := []struct {
+		name     string
+		seq      iter.Seq[int]
+		expected []int
+	}{
+		{"slices.Values", slices.Values([]int{3, 1, 2}), []int{3, 1, 2}},
+		{"empty", slices.Values([]int(nil)), nil},
+		{"FromSeq(ToSeq())", itertest.FromSlice(ints(10)).ToSeq(), []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+	}
+	for _, test := range tests {
+		if actual := FromSeq(test.seq).Collect(); !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf("FromSeq(%s): expecting %v, got %v", test.name, test.expected, actual)
+		}
+	}
+
+	itertest.CheckNoLeaks(t, func() {
+		stopped := make(chan struct{})
+		infinite := func(yield func(int) bool) {
+			defer close(stopped)
+			for i := 0; yield(i); i++ {
+			}
+		}
+		if actual := FromSeq(infinite).Take(3).Collect(); !reflect.DeepEqual([]int{0, 1, 2}, actual) {
+			t.Errorf("FromSeq(infinite).Take(3): expecting [0 1 2], got %v", actual)
+		}
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Errorf("FromSeq(infinite).Take(3): expecting yield to return false once the Iter is closed")
+		}
+	})
+}
+
+func TestParMap(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	delays := make([]time.Duration, 200)
+	for i := range delays {
+		delays[i] = time.Duration(rng.Intn(100)) * time.Microsecond
+	}
+	slowSquare := func(x int) int {
+		time.Sleep(delays[x])
+		return x * x
+	}
+	expected := itertest.FromSlice(ints(len(delays))).Map(slowSquare).Collect()
+	for _, workers := range []int{-1, 1, 4, 32} {
+		if actual := itertest.FromSlice(ints(len(delays))).ParMap(workers, slowSquare).Collect(); !reflect.DeepEqual(expected, actual) {
+			t.Errorf("ParMap(%d, slowSquare): expecting %v, got %v", workers, expected, actual)
+		}
+	}
+	if actual := itertest.FromSlice(ints(0)).ParMap(4, slowSquare).Collect(); actual != nil {
+		t.Errorf("ParMap(4, slowSquare) of empty: expecting nil, got %v", actual)
+	}
+	expectedStrings := []string{"0", "2", "4"}
+	itertest.AssertEqual(t, ParMap(Seq().Filter(func(x int) bool { return x%2 == 0 }), 4, strconv.Itoa).Take(3), expectedStrings)
+}
+
+func TestParMapBoundsInFlight(t *testing.T) {
+	workers := 4
+	itertest.CheckNoLeaks(t, func() {
+		release := make(chan struct{})
+		var calls int64
+		source, src := countingSource(1000)
+		it := source.ParMap(workers, func(x int) int {
+			atomic.AddInt64(&calls, 1)
+			if x == 0 {
+				<-release
+			}
+			return x
+		})
+		// with the head blocked, the other workers fill the queue of workers results and then stop,
+		// after fn has been called with the head and the workers elements behind it
+		for deadline := time.Now().Add(time.Second); atomic.LoadInt64(&calls) < int64(workers+1) && time.Now().Before(deadline); {
+			runtime.Gosched()
+		}
+		if n := src.received(); n > int64(2*workers+2) {
+			t.Errorf("ParMap(%d) with a blocked head: expecting at most %d elements received from the source, got %d", workers, 2*workers+2, n)
+		}
+		if n := atomic.LoadInt64(&calls); n > int64(2*workers+1) {
+			t.Errorf("ParMap(%d) with a blocked head: expecting at most %d calls of fn, got %d", workers, 2*workers+1, n)
+		}
+		close(release)
+		if expected, actual := []int{0, 1, 2}, it.CollectN(3); !reflect.DeepEqual(expected, actual) {
+			t.Errorf("ParMap(%d).CollectN(3): expecting %v, got %v", workers, expected, actual)
+		}
+		it.Close()
+		src.close()
+	})
+}
+
+func BenchmarkParMap(b *testing.B) {
+	spin := func(x int) int {
+		for i := 0; i < 100000; i++ {
+			x = x*31 + i
+		}
+		return x
+	}
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				Range(0, 64).ParMap(workers, spin).Drain()
+			}
+		})
+	}
+}
+
+func TestParFilter(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	delays := make([]time.Duration, 200)
+	for i := range delays {
+		delays[i] = time.Duration(rng.Intn(100)) * time.Microsecond
+	}
+	slowOdd := func(x int) bool {
+		time.Sleep(delays[x])
+		return x%2 == 1
+	}
+	expected := itertest.FromSlice(ints(len(delays))).Filter(slowOdd).Collect()
+	for _, workers := range []int{-1, 1, 4, 32} {
+		if actual := itertest.FromSlice(ints(len(delays))).ParFilter(workers, slowOdd).Collect(); !reflect.DeepEqual(expected, actual) {
+			t.Errorf("ParFilter(%d, slowOdd): expecting %v, got %v", workers, expected, actual)
+		}
+	}
+	if actual := itertest.FromSlice(ints(len(delays))).ParFilter(4, func(int) bool { return false }).Collect(); actual != nil {
+		t.Errorf("ParFilter(4, none): expecting nil, got %v", actual)
+	}
+	if expected, actual := []int{1, 3, 5}, Seq().ParFilter(4, func(x int) bool { return x%2 == 1 }).Take(3).Collect(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("ParFilter(4, odd).Take(3): expecting %v, got %v", expected, actual)
+	}
+}
+
+func BenchmarkParFilter(b *testing.B) {
+	isPrime := func(n int) bool {
+		if n < 2 {
+			return false
+		}
+		for d := 2; d*d <= n; d++ {
+			if n%d == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	from := 1 << 40
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				Range(from, from+64).ParFilter(workers, isPrime).Drain()
+			}
+		})
+	}
+}
+
+func TestParReduce(t *testing.T) {
+	// 2x2 matrices modulo 251 packed into an int, one byte per entry: multiplication is associative but not commutative
+	const p = 251
+	entry := func(m, i int) int { return m >> (8 * i) & 0xff }
+	mul := func(a, b int) int {
+		a0, a1, a2, a3 := entry(a, 0), entry(a, 1), entry(a, 2), entry(a, 3)
+		b0, b1, b2, b3 := entry(b, 0), entry(b, 1), entry(b, 2), entry(b, 3)
+		return (a0*b0+a1*b2)%p | (a0*b1+a1*b3)%p<<8 | (a2*b0+a3*b2)%p<<16 | (a2*b1+a3*b3)%p<<24
+	}
+	identity := 1 | 1<<24
+	matrix := func(x int) int { return x%p | 1<<8 | (x*7)%p<<16 | (x*x)%p<<24 }
+	if mul(matrix(2), matrix(3)) == mul(matrix(3), matrix(2)) {
+		t.Fatalf("mul: expecting the test operation not to be commutative")
+	}
+
+	add := func(a, b int) int { return a + b }
+	max := func(a, b int) int {
+		if a > b {
+			return a
+		}
+		return b
+	}
+	rng := rand.New(rand.NewSource(3))
+	s := make([]int, 5000)
+	for i := range s {
+		s[i] = rng.Intn(1000000) - 500000
+	}
+	tests := []struct {
+		name     string
+		identity int
+		fn       func(int, int) int
+		s        []int
+	}{
+		{"sum", 0, add, s},
+		{"max", math.MinInt, max, s},
+		{"matrix product", identity, mul, Map(itertest.FromSlice(s), matrix).Collect()},
+		{"sum of empty", 0, add, nil},
+		{"sum of one chunk", 0, add, s[:10]},
+	}
+	for _, test := range tests {
+		expected := itertest.FromSlice(test.s).Reduce(test.identity, test.fn)
+		for _, workers := range []int{-1, 1, 3, 16} {
+			if actual := itertest.FromSlice(test.s).ParReduce(workers, test.identity, test.fn); actual != expected {
+				t.Errorf("ParReduce(%d) %s: expecting %d, got %d", workers, test.name, expected, actual)
+			}
+		}
+	}
+}
+
+func BenchmarkReduceSum(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Range(0, 10000000).Reduce(0, func(a, b int) int { return a + b })
+	}
+}
+
+func BenchmarkParReduceSum(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Range(0, 10000000).ParReduce(0, 0, func(a, b int) int { return a + b })
+	}
+}
+
+func TestSizeHint(t *testing.T) {
+	ch := make(chan int, 3)
+	ch <- 1
+	ch <- 2
+	ch <- 3
+	close(ch)
+	even := func(x int) bool { return x%2 == 0 }
+	tests := []struct {
+		name  string
+		it    Iter[int]
+		n     int
+		exact bool
+	}{
+		{"Range(0, 10)", Range(0, 10), 10, true},
+		{"RangeInclusive(1, 10)", RangeInclusive(1, 10), 10, true},
+		{"RangeStep(0, 10, 3)", RangeStep(0, 10, 3), 4, true},
+		{"RangeStep(10, 0, -3)", RangeStep(10, 0, -3), 4, true},
+		{"RepeatN(7, 5)", RepeatN(7, 5), 5, true},
+		{"FromSlices", FromSlices([][]int{{1, 2}, nil, {3}}), 3, true},
+		{"Map", Range(0, 10).Map(func(x int) int { return x * x }), 10, true},
+		{"ParMap", Range(0, 10).ParMap(4, func(x int) int { return x * x }), 10, true},
+		{"Take(3)", Range(0, 10).Take(3), 3, true},
+		{"Take(20)", Range(0, 10).Take(20), 10, true},
+		{"Drop(3)", Range(0, 10).Drop(3), 7, true},
+		{"WithContext", Range(0, 10).WithContext(context.Background()), 10, false},
+		{"Seq().Take(5)", Seq().Take(5), 5, false},
+		{"SeqFrom(MinInt + 5, -3)", SeqFrom(math.MinInt+5, -3), 2, true},
+		{"Filter", Range(0, 10).Filter(even), -1, false},
+		{"Filter.Take(3)", Range(0, 10).Filter(even).Take(3), 3, false},
+		{"Filter.Take(20)", Range(0, 10).Filter(even).Take(20), 20, false},
+		{"FromChan", FromChan(ch), -1, false},
+		{"user channel", Iter[int](make(chan int)), -1, false},
+	}
+	for _, test := range tests {
+		n, exact := test.it.SizeHint()
+		if n != test.n || exact != test.exact {
+			t.Errorf("%s.SizeHint(): expecting (%d, %t), got (%d, %t)", test.name, test.n, test.exact, n, exact)
+		}
+		if test.name == "user channel" {
+			continue
+		}
+		if actual := test.it.Collect(); (exact && len(actual) != n) || len(actual) > n && n >= 0 {
+			t.Errorf("%s.Collect(): expecting a length consistent with (%d, %t), got %v", test.name, n, exact, actual)
+		}
+	}
+
+	huge := RangeInclusive(math.MinInt, math.MaxInt)
+	if n, exact := huge.SizeHint(); n != -1 || exact {
+		t.Errorf("RangeInclusive(math.MinInt, math.MaxInt).SizeHint(): expecting (-1, false), got (%d, %t)", n, exact)
+	}
+	huge.Close()
+}
+
+func TestSizeHintNeverTruncates(t *testing.T) {
+	it := Range(0, 10)
+	<-it
+	<-it
+	itertest.AssertEqual(t, it, []int{2, 3, 4, 5, 6, 7, 8, 9})
+	odd := func(x int) bool { return x%2 == 1 }
+	if expected, actual := []int{1, 3, 5, 7, 9}, Range(0, 10).Filter(odd).Take(100).Collect(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Filter(odd).Take(100).Collect(): expecting %v, got %v", expected, actual)
+	}
+}
+
+func TestCollectCapsPreallocation(t *testing.T) {
+	limit := (4 << 20) / 8
+	tests := []struct {
+		n, expected int
+	}{
+		{0, 0},
+		{1000, 1000},
+		{limit, limit},
+		{limit + 1, limit},
+		{math.MaxInt32, limit},
+	}
+	for _, test := range tests {
+		if actual := CollectCap(test.n); actual != test.expected {
+			t.Errorf("CollectCap(%d): expecting %d, got %d", test.n, test.expected, actual)
+		}
+	}
+
+	// a Range closed after a few elements still has an exact hint of its full size
+	r := Range(0, 1<<30)
+	it := r.Map(func(x int) int {
+		if x == 10 {
+			r.Close()
+		}
+		return x
+	})
+	if actual := it.Collect(); cap(actual) > limit {
+		t.Errorf("Collect() of Range(0, 1<<30) closed after %d elements: expecting a capacity of at most %d, got %d", len(actual), limit, cap(actual))
+	}
+}
+
+func BenchmarkCollectSized(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Range(0, 1000000).Map(func(x int) int { return x * 2 }).Collect()
+	}
+}
+
+func BenchmarkCollectUnsized(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Range(0, 1000000).Filter(func(int) bool { return true }).Collect()
+	}
+}
+
+func TestUnbounded(t *testing.T) {
+	isPrime := func(x int) bool {
+		for d := 2; d*d <= x; d++ {
+			if x%d == 0 {
+				return false
+			}
+		}
+		return x >= 2
+	}
+	double := func(x int) int { return x * 2 }
+	tests := []struct {
+		name     string
+		it       Iter[int]
+		expected bool
+	}{
+		{"Seq()", Seq(), true},
+		{"Repeat(1)", Repeat(1), true},
+		{"Primes()", Primes(), true},
+		{"Random()", Random(rand.New(rand.NewSource(1)), 0, 10), true},
+		{"Tick()", Tick(time.Hour), true},
+		{"Seq().Map(double)", Seq().Map(double), true},
+		{"Seq().Filter(isPrime)", Seq().Filter(isPrime), true},
+		{"Seq().Drop(5)", Seq().Drop(5), true},
+		{"Seq().ParMap(double)", Seq().ParMap(2, double), true},
+		{"Seq().Take(10)", Seq().Take(10), false},
+		{"Seq().WithContext()", Seq().WithContext(context.Background()), false},
+		{"SeqFrom(MaxInt - 2, 1)", SeqFrom(math.MaxInt-2, 1), false},
+		{"Range(0, 10)", Range(0, 10), false},
+		{"raw channel", Iter[int](make(chan int)), false},
+	}
+	for _, test := range tests {
+		if actual := test.it.Unbounded(); actual != test.expected {
+			t.Errorf("%s.Unbounded(): expecting %t, got %t", test.name, test.expected, actual)
+		}
+		test.it.Close()
+	}
+
+	it := Seq()
+	it.Close()
+	if it.Unbounded() {
+		t.Errorf("Seq() after Close(): expecting Unbounded() = false")
+	}
+	itertest.AssertEqual(t, Seq().Filter(isPrime).Take(5), []int{2, 3, 5, 7, 11})
+}
+
+func TestUnboundedPanics(t *testing.T) {
+	isEven := func(x int) bool { return x%2 == 0 }
+	tests := []struct {
+		name string
+		run  func()
+	}{
+		{"Collect", func() { Seq().Filter(isEven).Collect() }},
+		{"Count", func() { Repeat("x").Count() }},
+		{"Reduce", func() { Seq().Reduce(0, func(acc, cur int) int { return acc + cur }) }},
+		{"Reduce", func() { Reduce(Seq(), "", func(acc string, cur int) string { return acc + strconv.Itoa(cur) }) }},
+		{"ParReduce", func() { Seq().ParReduce(2, 0, func(a, b int) int { return a + b }) }},
+	}
+	for _, test := range tests {
+		itertest.CheckNoLeaks(t, func() {
+			defer func() {
+				if r := recover(); r == nil || !strings.HasPrefix(fmt.Sprint(r), test.name+": the Iter is unbounded") {
+					t.Errorf("%s on an unbounded Iter: expecting a panic naming it, got %v", test.name, r)
+				}
+			}()
+			test.run()
+		})
+	}
+}
+
+func TestCollectCtx(t *testing.T) {
+	stalled := func() Iter[int] {
+		ch := make(chan int)
+		go func() {
+			ch <- 1
+			ch <- 2
+		}()
+		return ch
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name     string
+		run      func() ([]int, error)
+		expected []int
+		err      error
+	}{
+		{"stalled producer", func() ([]int, error) { return stalled().CollectWithTimeout(20 * time.Millisecond) }, []int{1, 2}, context.DeadlineExceeded},
+		{"finite stream", func() ([]int, error) { return itertest.FromSlice(ints(5)).CollectWithTimeout(time.Second) }, []int{0, 1, 2, 3, 4}, nil},
+		{"cancelled before the first element", func() ([]int, error) { return Range(0, 10).CollectCtx(cancelled) }, nil, context.Canceled},
+		{"infinite stream", func() ([]int, error) { return Seq().CollectCtx(cancelled) }, nil, context.Canceled},
+	}
+	for _, test := range tests {
+		actual, err := test.run()
+		if !reflect.DeepEqual(test.expected, actual) || err != test.err {
+			t.Errorf("%s: expecting (%v, %v), got (%v, %v)", test.name, test.expected, test.err, actual, err)
+		}
+	}
+
+	itertest.CheckNoLeaks(t, func() {
+		s, err := Seq().Map(func(x int) int { return x * 2 }).CollectWithTimeout(10 * time.Millisecond)
+		if err != context.DeadlineExceeded || len(s) == 0 || s[len(s)-1] != 2*(len(s)-1) {
+			t.Errorf("Seq().Map(x * 2).CollectWithTimeout(): expecting the even numbers received and %v, got %d elements and %v", context.DeadlineExceeded, len(s), err)
+		}
+	})
+}
+
+func TestReduceCtx(t *testing.T) {
+	add := func(a, b int) int { return a + b }
+	if actual, err := itertest.FromSlice(ints(10)).ReduceCtx(context.Background(), 0, add); actual != 45 || err != nil {
+		t.Errorf("ReduceCtx(add) of itertest.FromSlice(ints(10)): expecting (45, nil), got (%d, %v)", actual, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	count, err := ReduceCtx(ctx, Seq(), 0, func(acc, _ int) int { return acc + 1 })
+	if err != context.DeadlineExceeded || count == 0 {
+		t.Errorf("ReduceCtx(count) of Seq(): expecting a positive count and %v, got (%d, %v)", context.DeadlineExceeded, count, err)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if actual, err := ReduceCtx(cancelled, Range(0, 10), "init", func(s string, _ int) string { return s + "." }); actual != "init" || err != context.Canceled {
+		t.Errorf("ReduceCtx(cancelled): expecting (init, %v), got (%s, %v)", context.Canceled, actual, err)
+	}
+}
+
+func TestMemoize(t *testing.T) {
+	size := 100
+	it, src := countingSource(size)
+	defer src.close()
+	c := it.Memoize()
+	expected := ints(size)
+
+	r := c.Iter()
+	itertest.AssertEqual(t, r.Take(10), expected[:10])
+	// wait for the reader to stop, though it may have received the next element before it was closed
+	itertest.AssertClosed(t, r, time.Second)
+	if n := src.received(); n != 10 && n != 11 {
+		t.Errorf("Memoize().Iter().Take(10): expecting 10 or 11 elements received from the source, got %d", n)
+	}
+	for i := 0; i < 2; i++ {
+		if actual := c.Iter().Collect(); !reflect.DeepEqual(expected, actual) {
+			t.Errorf("Memoize().Iter() #%d: expecting %v, got %v", i, expected, actual)
+		}
+	}
+	if n := src.received(); n != int64(size) {
+		t.Errorf("Memoize(): expecting %d elements received from the source, got %d", size, n)
+	}
+	if actual := itertest.FromSlice(ints(0)).Memoize().Iter().Collect(); actual != nil {
+		t.Errorf("Memoize() of empty: expecting nil, got %v", actual)
+	}
+}
+
+func TestMemoizeConcurrentReaders(t *testing.T) {
+	size := 1000
+	c := Range(0, size).Map(func(x int) int { return x * 3 }).Memoize()
+	expected := Range(0, size).Map(func(x int) int { return x * 3 }).Collect()
+	readers := 8
+	results := make([][]int, readers)
+	var wg sync.WaitGroup
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for x := range c.Iter() {
+				results[i] = append(results[i], x)
+				if x%(i+1) == 0 {
+					runtime.Gosched()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i, actual := range results {
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("reader #%d: expecting %d elements equal to the original, got %d elements", i, len(expected), len(actual))
+		}
+	}
+}
+
+func TestMemoizeClose(t *testing.T) {
+	// readers abandoned while one receives from a stalled source and the other waits for it
+	itertest.CheckNoLeaks(t, func() {
+		c := Iter[int](make(chan int)).Memoize()
+		r1, r2 := c.Iter(), c.Iter()
+		time.Sleep(10 * time.Millisecond)
+		r1.Close()
+		r2.Close()
+		itertest.AssertClosed(t, r1, time.Second)
+		itertest.AssertClosed(t, r2, time.Second)
+	})
+
+	// readers of an unbounded source, closed by the Cached
+	itertest.CheckNoLeaks(t, func() {
+		c := Seq().Memoize()
+		r1, r2 := c.Iter(), c.Iter()
+		itertest.AssertEqual(t, r1.Take(3), ints(3))
+		<-r2
+		c.Close()
+		c.Close()
+		itertest.AssertClosed(t, r2, time.Second)
+		itertest.AssertClosed(t, c.Iter(), time.Second)
+	})
+}
+
+func TestFork(t *testing.T) {
+	size := 1000
+	expected := ints(size)
+	a, b := itertest.FromSlice(ints(size)).Fork()
+	var actualA, actualB []int
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		actualA = a.Collect()
+	}()
+	go func() {
+		defer wg.Done()
+		for x := range b {
+			actualB = append(actualB, x)
+			if x%7 == 0 {
+				time.Sleep(time.Microsecond)
+			}
+		}
+	}()
+	wg.Wait()
+	if !reflect.DeepEqual(expected, actualA) || !reflect.DeepEqual(expected, actualB) {
+		t.Errorf("Fork(): expecting both forks to collect %d elements equal to the original, got %d and %d", size, len(actualA), len(actualB))
+	}
+
+	a, b = itertest.FromSlice(ints(0)).Fork()
+	if actualA, actualB := a.Collect(), b.Collect(); actualA != nil || actualB != nil {
+		t.Errorf("Fork() of empty: expecting nil and nil, got %v and %v", actualA, actualB)
+	}
+}
+
+func TestForkBuffersOnlyTheLag(t *testing.T) {
+	size := 1000
+	it, src := countingSource(size)
+	defer src.close()
+	a, b := it.Fork()
+	for i := 0; i < size; i++ {
+		xa, xb := <-a, <-b
+		if xa != i || xb != i {
+			t.Fatalf("Fork() in lockstep: expecting %d from both forks, got %d and %d", i, xa, xb)
+		}
+		// receiving one element from each fork needs one element from the source, and the next may be prefetched
+		if n := src.received(); n > int64(i+2) {
+			t.Fatalf("Fork() in lockstep: expecting at most %d elements received from the source, got %d", i+2, n)
+		}
+	}
+}
+
+func TestForkAbandoned(t *testing.T) {
+	a, b := itertest.FromSlice(ints(100)).Fork()
+	<-b
+	if actual := a.Collect(); !reflect.DeepEqual(ints(100), actual) {
+		t.Errorf("Fork() with the other fork abandoned: expecting %v, got %v", ints(100), actual)
+	}
+	b.Close()
+
+	itertest.CheckNoLeaks(t, func() {
+		a, b = Seq().Fork()
+		<-a
+		<-b
+		a.Close()
+		itertest.AssertEqual(t, b.Take(3), []int{1, 2, 3})
+	})
+}
+
+func TestResIter(t *testing.T) {
+	errBad := errors.New("bad token")
+	parse := func(s string) (int, error) {
+		x, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, errBad
+		}
+		return x, nil
+	}
+	tokens := func() Iter[string] { return FromSlices([][]string{{"1", "2", "x", "4", "y", "6"}}) }
+	even := func(x int) bool { return x%2 == 0 }
+	double := func(x int) int { return x * 2 }
+	tests := []struct {
+		name     string
+		r        ResIter[int]
+		expected []int
+		err      error
+	}{
+		{"MapErr", MapErr(tokens(), parse), []int{1, 2, 4, 6}, errBad},
+		{"MapErr.Filter.Map", MapErr(tokens(), parse).Filter(even).Map(double), []int{4, 8, 12}, errBad},
+		{"MapErr.StopOnError", MapErr(tokens(), parse).StopOnError(), []int{1, 2}, errBad},
+		{"MapErr.Filter.StopOnError", MapErr(tokens(), parse).Filter(even).StopOnError(), []int{2}, errBad},
+		{"no error", MapErr(FromSlices([][]string{{"3", "5"}}), parse).StopOnError(), []int{3, 5}, nil},
+		{"empty", itertest.FromSlice(ints(0)).MapErr(func(x int) (int, error) { return x, nil }), nil, nil},
+	}
+	for _, test := range tests {
+		if actual, err := test.r.CollectErr(); !reflect.DeepEqual(test.expected, actual) || err != test.err {
+			t.Errorf("%s.CollectErr(): expecting (%v, %v), got (%v, %v)", test.name, test.expected, test.err, actual, err)
+		}
+	}
+
+	odd := func(x int) bool { return x%2 == 1 }
+	square := func(x int) int { return x * x }
+	noErr := func(x int) (int, error) { return x, nil }
+	expected := itertest.FromSlice(ints(20)).Filter(odd).Map(square).Collect()
+	if actual, err := itertest.FromSlice(ints(20)).MapErr(noErr).Filter(odd).Map(square).CollectErr(); !reflect.DeepEqual(expected, actual) || err != nil {
+		t.Errorf("MapErr(noErr).Filter(odd).Map(square).CollectErr(): expecting (%v, nil), got (%v, %v)", expected, actual, err)
+	}
+}
+
+func TestStopOnErrorReleasesPipeline(t *testing.T) {
+	itertest.CheckNoLeaks(t, func() {
+		fail := func(x int) (int, error) {
+			if x == 3 {
+				return 0, errWriteFailed
+			}
+			return x, nil
+		}
+		if actual, err := Seq().MapErr(fail).StopOnError().CollectErr(); !reflect.DeepEqual([]int{0, 1, 2}, actual) || err != errWriteFailed {
+			t.Errorf("Seq().MapErr(fail).StopOnError().CollectErr(): expecting ([0 1 2], %v), got (%v, %v)", errWriteFailed, actual, err)
+		}
+	})
+}
+
+func TestInstrument(t *testing.T) {
+	var source, slow Metrics
+	size := 10
+	actual := Range(0, size).
+		Instrument("source", &source).
+		Map(func(x int) int {
+			time.Sleep(2 * time.Millisecond)
+			return x
+		}).
+		Instrument("slow", &slow).
+		Filter(func(x int) bool { return x%2 == 0 }).
+		Collect()
+	if expected := []int{0, 2, 4, 6, 8}; !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Instrument(): expecting the elements %v unchanged, got %v", expected, actual)
+	}
+
+	src, sl := source.Snapshot(), slow.Snapshot()
+	if src.Name != "source" || sl.Name != "slow" {
+		t.Errorf("Snapshot().Name: expecting source and slow, got %s and %s", src.Name, sl.Name)
+	}
+	if src.Elements != int64(size) || sl.Elements != int64(size) {
+		t.Errorf("Snapshot().Elements: expecting %d and %d, got %d and %d", size, size, src.Elements, sl.Elements)
+	}
+	// the source stage waits for the slow Map downstream, and the slow stage waits for it upstream
+	if src.BlockedSend < 10*time.Millisecond {
+		t.Errorf("source BlockedSend: expecting at least 10ms, got %v", src.BlockedSend)
+	}
+	if sl.BlockedRecv < 10*time.Millisecond {
+		t.Errorf("slow BlockedRecv: expecting at least 10ms, got %v", sl.BlockedRecv)
+	}
+	if src.First.IsZero() || src.Last.Before(src.First) {
+		t.Errorf("source First and Last: expecting First <= Last, got %v and %v", src.First, src.Last)
+	}
+}
+
+func TestInstrumentClock(t *testing.T) {
+	// every call of the clock advances it by a second
+	start := time.Unix(1000, 0)
+	calls := 0
+	m := Metrics{Clock: func() time.Time {
+		calls++
+		return start.Add(time.Duration(calls) * time.Second)
+	}}
+	if snap := m.Snapshot(); snap.Elements != 0 || !snap.First.IsZero() || !snap.Last.IsZero() {
+		t.Errorf("Snapshot() before Instrument: expecting zero values, got %+v", snap)
+	}
+	itertest.FromSlice(ints(3)).Instrument("clock", &m).Drain()
+	expected := MetricsSnapshot{
+		Name:        "clock",
+		Elements:    3,
+		First:       start.Add(3 * time.Second),
+		Last:        start.Add(9 * time.Second),
+		BlockedSend: 3 * time.Second,
+		BlockedRecv: 4 * time.Second,
+	}
+	if actual := m.Snapshot(); !actual.First.Equal(expected.First) || !actual.Last.Equal(expected.Last) ||
+		actual.Name != expected.Name || actual.Elements != expected.Elements ||
+		actual.BlockedSend != expected.BlockedSend || actual.BlockedRecv != expected.BlockedRecv {
+		t.Errorf("Snapshot(): expecting %+v, got %+v", expected, actual)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	var buf bytes.Buffer
+	actual := Range(1, 4).
+		Trace(&buf, "a").
+		Map(func(x int) int { return x * 10 }).
+		Trace(&buf, "b").
+		Collect()
+	if expected := []int{10, 20, 30}; !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Trace(): expecting the elements %v untouched, got %v", expected, actual)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	// the two stages run concurrently, so only the order of each stage's lines, and of each element's lines, is fixed
+	expected := map[string][]string{
+		"a": {"a: 1", "a: 2", "a: 3", "a: <closed>"},
+		"b": {"b: 10", "b: 20", "b: 30", "b: <closed>"},
+	}
+	byLabel := map[string][]string{}
+	position := map[string]int{}
+	for i, line := range lines {
+		label, _, _ := strings.Cut(line, ":")
+		byLabel[label] = append(byLabel[label], line)
+		position[line] = i
+	}
+	if !reflect.DeepEqual(expected, byLabel) {
+		t.Errorf("Trace() lines: expecting %v, got %q", expected, lines)
+	}
+	for i := range expected["a"] {
+		if position[expected["a"][i]] > position[expected["b"][i]] {
+			t.Errorf("Trace() lines: expecting %q before %q, got %q", expected["a"][i], expected["b"][i], lines)
+		}
+	}
+
+	it := itertest.FromSlice(ints(3))
+	if it.Trace(nil, "nil") != it || it.Trace(io.Discard, "discard") != it {
+		t.Errorf("Trace(nil) and Trace(io.Discard): expecting the original Iter")
+	}
+	it.Drain()
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		it       Iter[int]
+		expected string
+	}{
+		{Range(0, 10), "Iter[int](10 elements, 5 buffered: [0 1 2 3 4] ...)"},
+		{Range(0, 3), "Iter[int](3 elements, 3 buffered: [0 1 2])"},
+		{Seq().Take(5), "Iter[int](at most 5 elements, 5 buffered: [0 1 2 3 4])"},
+		{Seq().Filter(func(x int) bool { return x%2 == 0 }), "Iter[int](unbounded, 5 buffered: [0 2 4 6 8] ...)"},
+		{Iter[int](make(chan int)), "Iter[int](unknown size)"},
+	}
+	for _, test := range tests {
+		if actual := fmt.Sprint(test.it); actual != test.expected {
+			t.Errorf("Sprint(): expecting %q, got %q", test.expected, actual)
+		}
+		test.it.Close()
+	}
+	if expected, actual := "Iter[string](2 elements, 2 buffered: [a b])", fmt.Sprint(FromSlices([][]string{{"a", "b"}})); actual != expected {
+		t.Errorf("Sprint(): expecting %q, got %q", expected, actual)
+	}
+}
+
+func TestStringKeepsElements(t *testing.T) {
+	it := Range(0, 10)
+	first, second := fmt.Sprint(it), fmt.Sprint(it)
+	if first != second {
+		t.Errorf("Sprint() twice: expecting the same elements shown, got %q and %q", first, second)
+	}
+	itertest.AssertEqual(t, it, Range(0, 10).Collect())
+
+	// elements received between two calls are no longer shown
+	it = Range(0, 10).Map(func(x int) int { return x * x })
+	_ = fmt.Sprint(it)
+	<-it
+	<-it
+	if expected, actual := "Iter[int](10 elements, 5 buffered: [4 9 16 25 36] ...)", fmt.Sprint(it); actual != expected {
+		t.Errorf("Sprint() after receiving 2 elements: expecting %q, got %q", expected, actual)
+	}
+	itertest.AssertEqual(t, it, []int{4, 9, 16, 25, 36, 49, 64, 81})
+
+	infinite := Seq()
+	_ = fmt.Sprint(infinite)
+	itertest.AssertEqual(t, infinite.Take(8), Range(0, 8).Collect())
+}
+
+func TestStringThenClose(t *testing.T) {
+	// the stage has sent all of its elements to String, and still holds them when closed
+	itertest.CheckNoLeaks(t, func() {
+		it := Range(0, 3)
+		_ = fmt.Sprint(it)
+		if x, ok := it.First(); x != 0 || !ok {
+			t.Errorf("First() after Sprint(): expecting (0, true), got (%d, %t)", x, ok)
+		}
+		it.Close()
+	})
+}
+
+func TestStringDoesNotHang(t *testing.T) {
+	tests := []struct {
+		name     string
+		it       func() Iter[int]
+		expected string
+	}{
+		{"empty", func() Iter[int] { return Range(0, 0) }, "Iter[int](unknown size)"},
+		{"ended", func() Iter[int] {
+			it := RepeatN(1, 3)
+			it.Drain()
+			return it
+		}, "Iter[int](unknown size)"},
+		{"stalled", func() Iter[int] { return Iter[int](make(chan int)).Map(func(x int) int { return x }) }, "Iter[int](unknown size)"},
+	}
+	for _, test := range tests {
+		it := test.it()
+		start := time.Now()
+		if actual := fmt.Sprint(it); actual != test.expected || time.Since(start) > time.Second {
+			t.Errorf("Sprint() of a %s Iter: expecting %q at once, got %q after %v", test.name, test.expected, actual, time.Since(start))
+		}
+		it.Close()
+	}
+
+	// String gives up on a stalled Iter, which sends its elements once they come
+	stalled := make(chan int)
+	it := Iter[int](stalled).Map(func(x int) int { return x * 2 })
+	if expected, actual := "Iter[int](unknown size)", fmt.Sprint(it); actual != expected {
+		t.Errorf("Sprint() of a stalled Iter: expecting %q, got %q", expected, actual)
+	}
+	go func() {
+		stalled <- 7
+		close(stalled)
+	}()
+	itertest.AssertEqual(t, it, []int{14})
+}
+
+func TestCheckpointResume(t *testing.T) {
+	square := func(x int) int { return x * x }
+	tests := []struct {
+		name   string
+		source func() Iter[int]
+	}{
+		{"Range(0, 100)", func() Iter[int] { return Range(0, 100) }},
+		{"RangeStep(100, 0, -3)", func() Iter[int] { return RangeStep(100, 0, -3) }},
+		{"RangeInclusive(1, 50)", func() Iter[int] { return RangeInclusive(1, 50) }},
+	}
+	for _, test := range tests {
+		expected := test.source().Map(square).Collect()
+
+		// the first run crashes after receiving 25 elements, 20 of which have been checkpointed
+		var saved []byte
+		var first []int
+		it := test.source().Map(square).Checkpoint(10, func(state CheckpointState) error {
+			var err error
+			saved, err = json.Marshal(state)
+			return err
+		})
+		for res := range it {
+			if first = append(first, res.Val); len(first) == 25 {
+				break
+			}
+		}
+		it.Close()
+
+		var state CheckpointState
+		if err := json.Unmarshal(saved, &state); err != nil {
+			t.Fatalf("%s: expecting the saved state to be decoded, got %v", test.name, err)
+		}
+		if state.Emitted != 20 {
+			t.Errorf("%s: expecting 20 elements emitted, got %d", test.name, state.Emitted)
+		}
+		resumed, err := ResumeRange(state)
+		if err != nil {
+			t.Fatalf("%s: expecting ResumeRange to succeed, got %v", test.name, err)
+		}
+		actual := append(first[:state.Emitted], resumed.Map(square).Collect()...)
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("%s: expecting the two runs to make %v, got %v", test.name, expected, actual)
+		}
+	}
+}
+
+func TestCheckpointSaves(t *testing.T) {
+	tests := []struct {
+		name     string
+		it       Iter[int]
+		expected []CheckpointState
+	}{
+		{"Range(0, 7)", Range(0, 7), []CheckpointState{{3, &RangeState{3, 7, 1}}, {6, &RangeState{6, 7, 1}}, {7, &RangeState{7, 7, 1}}}},
+		{"Range(0, 6)", Range(0, 6), []CheckpointState{{3, &RangeState{3, 6, 1}}, {6, &RangeState{6, 6, 1}}}},
+		{"RangeStep(0, 10, 4)", RangeStep(0, 10, 4), []CheckpointState{{3, &RangeState{10, 10, 4}}}},
+		{"FromSlice()", itertest.FromSlice([]int{1, 2, 3, 4}), []CheckpointState{{3, nil}, {4, nil}}},
+		{"Range(0, 0)", Range(0, 0), []CheckpointState{{0, nil}}},
+	}
+	for _, test := range tests {
+		var actual []CheckpointState
+		test.it.Checkpoint(3, func(state CheckpointState) error {
+			actual = append(actual, state)
+			return nil
+		}).CollectErr()
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf("%s.Checkpoint(3): expecting saves of %v, got %v", test.name, test.expected, actual)
+		}
+	}
+
+	if _, err := ResumeRange(CheckpointState{Emitted: 4}); !errors.Is(err, ErrNotResumable) {
+		t.Errorf("ResumeRange() without a range: expecting %v, got %v", ErrNotResumable, err)
+	}
+}
+
+func TestCheckpointSaveError(t *testing.T) {
+	itertest.CheckNoLeaks(t, func() {
+		saves := 0
+		actual, err := Seq().Checkpoint(3, func(CheckpointState) error {
+			if saves++; saves == 2 {
+				return errWriteFailed
+			}
+			return nil
+		}).CollectErr()
+		if expected := []int{0, 1, 2, 3, 4, 5}; !reflect.DeepEqual(expected, actual) || err != errWriteFailed {
+			t.Errorf("Seq().Checkpoint(3) failing on the second save: expecting (%v, %v), got (%v, %v)", expected, errWriteFailed, actual, err)
+		}
+	})
+}
+
+// ints returns the integers 0 through n-1, or nil if n <= 0.
+func ints(n int) []int {
+	var s []int
+	for i := 0; i < n; i++ {
+		s = append(s, i)
+	}
+	return s
 }
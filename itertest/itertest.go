@@ -0,0 +1,105 @@
+// Package itertest provides helpers for testing code built on goiter:
+// assertions on the elements of an Iter that fail instead of hanging on a stuck pipeline,
+// and a checker for goroutines that a pipeline leaves behind.
+//
+// itertest 包为基于 goiter 的代码提供测试辅助函数：对迭代器元素的断言在流水线卡住时会失败而不是挂起，
+// 以及检查流水线遗留的 goroutine 的工具。
+package itertest
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CoderYihaoWang/goiter"
+)
+
+// pkgPrefix starts the names of the functions of goiter in goroutine stacks, but not those of its sub-packages.
+const pkgPrefix = "github.com/CoderYihaoWang/goiter."
+
+// FromSlice creates an Iter of the elements of s.
+func FromSlice[T any](s []T) goiter.Iter[T] {
+	return goiter.FromSlices([][]T{s})
+}
+
+// AssertTimeout bounds the time AssertEqual waits for an Iter to end, so that a hung pipeline fails the test
+// instead of deadlocking it.
+var AssertTimeout = 5 * time.Second
+
+// AssertEqual fails the test unless it sends exactly the elements of want and then ends within AssertTimeout.
+// An empty want matches an empty Iter, whether want is nil or not.
+func AssertEqual[T any](t testing.TB, it goiter.Iter[T], want []T) {
+	t.Helper()
+	got, err := it.CollectWithTimeout(AssertTimeout)
+	if err != nil {
+		t.Errorf("expecting %v, got %v and no end within %v", want, got, AssertTimeout)
+		return
+	}
+	if (len(want) != 0 || len(got) != 0) && !reflect.DeepEqual(want, got) {
+		t.Errorf("expecting %v, got %v", want, got)
+	}
+}
+
+// AssertClosed fails the test unless it is closed within timeout. Elements still sent before that are discarded,
+// since an Iter may deliver an element it was already sending when it was closed.
+func AssertClosed[T any](t testing.TB, it goiter.Iter[T], timeout time.Duration) {
+	t.Helper()
+	deadline := time.After(timeout)
+	for {
+		select {
+		case _, ok := <-it:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Errorf("expecting the Iter to be closed within %v", timeout)
+			return
+		}
+	}
+}
+
+// CheckNoLeaks runs fn, and fails the test if any goroutine started by goiter during fn is still running
+// a second after fn returns, printing the stacks of those goroutines.
+func CheckNoLeaks(t testing.TB, fn func()) {
+	t.Helper()
+	before := iterGoroutines()
+	fn()
+	var leaked []string
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		leaked = leaked[:0]
+		for id, stack := range iterGoroutines() {
+			if _, ok := before[id]; !ok {
+				leaked = append(leaked, stack)
+			}
+		}
+		if len(leaked) == 0 || time.Now().After(deadline) {
+			break
+		}
+	}
+	if len(leaked) > 0 {
+		t.Errorf("expecting no leaked goroutines, got %d:\n%s", len(leaked), strings.Join(leaked, "\n\n"))
+	}
+}
+
+// iterGoroutines returns the stacks of the running goroutines that run or were created by code in goiter, by their IDs.
+func iterGoroutines() map[string]string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	stacks := make(map[string]string)
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		// every stack starts with a header like "goroutine 7 [chan send]:"
+		if fields := strings.Fields(stack); len(fields) > 1 && strings.Contains(stack, pkgPrefix) {
+			stacks[fields[1]] = stack
+		}
+	}
+	return stacks
+}
@@ -0,0 +1,57 @@
+package itertest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CoderYihaoWang/goiter"
+)
+
+// recordingT records failures instead of reporting them, for testing the test helpers themselves
+type recordingT struct {
+	testing.TB
+	failed bool
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...any) { r.failed = true }
+
+func TestHelpers(t *testing.T) {
+	defer func(timeout time.Duration) { AssertTimeout = timeout }(AssertTimeout)
+	AssertTimeout = 50 * time.Millisecond
+
+	r := &recordingT{TB: t}
+	AssertEqual(r, FromSlice([]int{1, 2, 3}), []int{1, 2, 3})
+	AssertEqual(r, goiter.FromSlices[int](nil), nil)
+	AssertClosed(r, goiter.FromSlices[int](nil), time.Second)
+	CheckNoLeaks(r, func() { goiter.Seq().Take(3).Collect() })
+	if r.failed {
+		t.Errorf("helpers on a correct pipeline: expecting no failure, got one")
+	}
+
+	hung := make(chan int)
+	tests := []struct {
+		name string
+		fn   func(testing.TB)
+	}{
+		{"AssertEqual(different elements)", func(t testing.TB) { AssertEqual(t, FromSlice([]int{1, 2}), []int{1, 3}) }},
+		{"AssertEqual(hung Iter)", func(t testing.TB) { AssertEqual(t, goiter.Iter[int](hung), nil) }},
+		{"AssertClosed(hung Iter)", func(t testing.TB) { AssertClosed(t, goiter.Iter[int](hung), 50*time.Millisecond) }},
+	}
+	for _, test := range tests {
+		r := &recordingT{TB: t}
+		test.fn(r)
+		if !r.failed {
+			t.Errorf("%s: expecting a failure, got none", test.name)
+		}
+	}
+
+	var leaked goiter.Iter[int]
+	r = &recordingT{TB: t}
+	CheckNoLeaks(r, func() { leaked = goiter.Seq().Map(func(x int) int { return x * 2 }) })
+	leaked.Close()
+	if !r.failed {
+		t.Errorf("CheckNoLeaks(unclosed Seq): expecting a failure, got none")
+	}
+}